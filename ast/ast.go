@@ -0,0 +1,271 @@
+// Package ast defines the intermediate representation of a document that
+// sits between the lark blocks returned by the OPEN API and the renderers.
+package ast
+
+// Node is any element of a document tree.
+type Node interface {
+	node()
+}
+
+// Block is a node that occupies its own vertical space, like a paragraph.
+type Block interface {
+	Node
+	block()
+}
+
+// Inline is a node that lives inside the content of a block, like a run
+// of styled text.
+type Inline interface {
+	Node
+	inline()
+}
+
+// =============================================================
+// Block nodes
+// =============================================================
+
+// Document is the root of a parsed document.
+type Document struct {
+	ID         string
+	RevisionID int64
+	Title      string
+	Children   []Block
+}
+
+// Heading is a heading of level 1 to 9.
+type Heading struct {
+	Level   int
+	Content []Inline
+}
+
+// Paragraph is a plain text block.
+type Paragraph struct {
+	Content []Inline
+}
+
+// List groups consecutive list items of the same kind.
+type List struct {
+	Ordered bool
+	Task    bool
+	Items   []*ListItem
+}
+
+// ListItem is a single item of a list, Done is only meaningful in a task
+// list.
+type ListItem struct {
+	Done     bool
+	Content  []Inline
+	Children []Block
+}
+
+// Code is a fenced code block, its content keeps the inline structure as
+// links may be embedded in the code.
+type Code struct {
+	Language string
+	Content  []Inline
+}
+
+// Math is a display equation in KaTeX syntax.
+type Math struct {
+	Content string
+}
+
+// Blockquote quotes its children.
+type Blockquote struct {
+	Children []Block
+}
+
+// Callout is a highlighted box. BackgroundColor is a color name such as
+// "light-red" or "dark-blue", Emoji is the rendered icon if any.
+type Callout struct {
+	Emoji           string
+	BackgroundColor string
+	Children        []Block
+}
+
+// Grid lays its columns out side by side.
+type Grid struct {
+	Columns []*GridColumn
+}
+
+// GridColumn is a column of a grid, WidthRatio is its share of the grid
+// width in percent.
+type GridColumn struct {
+	WidthRatio int
+	Children   []Block
+}
+
+// Table is a grid of cells, the first row being the header. A nil cell is
+// covered by a merged neighbour.
+type Table struct {
+	Rows [][]*TableCell
+}
+
+// TableCell is a cell of a table spanning RowSpan rows and ColSpan
+// columns.
+type TableCell struct {
+	RowSpan  int
+	ColSpan  int
+	Children []Block
+}
+
+// ThematicBreak is a horizontal divider.
+type ThematicBreak struct{}
+
+// Image is a picture identified by its file token. Src is where the
+// rendered output points to, it is the token until the image has been
+// downloaded. Align is left, center or right, empty when unknown.
+type Image struct {
+	Token   string
+	Src     string
+	Width   int
+	Height  int
+	Align   string
+	Caption string
+}
+
+// File is an attachment identified by its file token. Src is where the
+// rendered link points to, it is the token until the file has been
+// downloaded, and Size is only known after that.
+type File struct {
+	Token string
+	Name  string
+	Src   string
+	Size  int64
+}
+
+// Iframe embeds a web page such as a video or a design, Type is the name
+// of the component like "Figma".
+type Iframe struct {
+	Type string
+	URL  string
+}
+
+// Diagram is a drawing kept in the cloud, such as a flowchart, a whiteboard
+// or a mindnote. Src is its exported image if any, otherwise URL links back
+// to the block in the source document.
+type Diagram struct {
+	Kind    string
+	BlockID string
+	Token   string
+	Src     string
+	URL     string
+}
+
+// Supported values of Diagram.Kind
+const (
+	DiagramKindDiagram    = "diagram"
+	DiagramKindWhiteboard = "whiteboard"
+	DiagramKindMindnote   = "mindnote"
+)
+
+// Sheet is a spreadsheet embedded by its token. Table holds its values
+// once fetched, otherwise Src links to them if they were saved aside.
+type Sheet struct {
+	Token string
+	Src   string
+	Table *Table
+}
+
+// Bitable is a multi-dimensional table embedded by its token, with its
+// records as Table or saved aside at Src like a Sheet.
+type Bitable struct {
+	Token string
+	Src   string
+	Table *Table
+}
+
+// =============================================================
+// Inline nodes
+// =============================================================
+
+// Mark is a set of styles applied to a text run.
+type Mark uint8
+
+const (
+	Bold Mark = 1 << iota
+	Italic
+	Strikethrough
+	Underline
+	InlineCode
+)
+
+// Has reports whether all marks of x are set.
+func (m Mark) Has(x Mark) bool {
+	return m&x == x
+}
+
+// Text is a run of text sharing the same marks, Link is set when the run
+// is a hyperlink.
+type Text struct {
+	Content string
+	Marks   Mark
+	Link    string
+}
+
+// UserMention refers to a user by id.
+type UserMention struct {
+	UserID string
+	// Name and Email are only filled if the user is resolved
+	Name  string
+	Email string
+}
+
+// DocMention refers to another cloud document.
+type DocMention struct {
+	Token string
+	Title string
+	URL   string
+}
+
+// InlineMath is an equation in KaTeX syntax.
+type InlineMath struct {
+	Content string
+}
+
+func (*Document) node()      {}
+func (*Heading) node()       {}
+func (*Paragraph) node()     {}
+func (*List) node()          {}
+func (*ListItem) node()      {}
+func (*Code) node()          {}
+func (*Math) node()          {}
+func (*Blockquote) node()    {}
+func (*Callout) node()       {}
+func (*Grid) node()          {}
+func (*GridColumn) node()    {}
+func (*Table) node()         {}
+func (*TableCell) node()     {}
+func (*ThematicBreak) node() {}
+func (*Image) node()         {}
+func (*File) node()          {}
+func (*Iframe) node()        {}
+func (*Diagram) node()       {}
+func (*Sheet) node()         {}
+func (*Bitable) node()       {}
+func (*Text) node()          {}
+func (*UserMention) node()   {}
+func (*DocMention) node()    {}
+func (*InlineMath) node()    {}
+
+func (*Heading) block()       {}
+func (*Paragraph) block()     {}
+func (*List) block()          {}
+func (*Code) block()          {}
+func (*Math) block()          {}
+func (*Blockquote) block()    {}
+func (*Callout) block()       {}
+func (*Grid) block()          {}
+func (*ThematicBreak) block() {}
+func (*Image) block()         {}
+func (*Table) block()         {}
+func (*File) block()          {}
+func (*Iframe) block()        {}
+func (*Diagram) block()       {}
+func (*Sheet) block()         {}
+func (*Bitable) block()       {}
+
+func (*Text) inline()        {}
+func (*UserMention) inline() {}
+func (*DocMention) inline()  {}
+func (*InlineMath) inline()  {}
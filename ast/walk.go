@@ -0,0 +1,66 @@
+package ast
+
+// Walk traverses the tree rooted at n depth-first and calls fn for every
+// node. Returning false from fn skips the children of that node.
+func Walk(n Node, fn func(Node) bool) {
+	if n == nil || !fn(n) {
+		return
+	}
+	switch n := n.(type) {
+	case *Document:
+		walkBlocks(n.Children, fn)
+	case *Heading:
+		walkInlines(n.Content, fn)
+	case *Paragraph:
+		walkInlines(n.Content, fn)
+	case *Code:
+		walkInlines(n.Content, fn)
+	case *List:
+		for _, item := range n.Items {
+			Walk(item, fn)
+		}
+	case *ListItem:
+		walkInlines(n.Content, fn)
+		walkBlocks(n.Children, fn)
+	case *Blockquote:
+		walkBlocks(n.Children, fn)
+	case *Callout:
+		walkBlocks(n.Children, fn)
+	case *Grid:
+		for _, column := range n.Columns {
+			Walk(column, fn)
+		}
+	case *GridColumn:
+		walkBlocks(n.Children, fn)
+	case *Table:
+		for _, row := range n.Rows {
+			for _, cell := range row {
+				if cell != nil {
+					Walk(cell, fn)
+				}
+			}
+		}
+	case *TableCell:
+		walkBlocks(n.Children, fn)
+	case *Sheet:
+		if n.Table != nil {
+			Walk(n.Table, fn)
+		}
+	case *Bitable:
+		if n.Table != nil {
+			Walk(n.Table, fn)
+		}
+	}
+}
+
+func walkBlocks(blocks []Block, fn func(Node) bool) {
+	for _, b := range blocks {
+		Walk(b, fn)
+	}
+}
+
+func walkInlines(inlines []Inline, fn func(Node) bool) {
+	for _, i := range inlines {
+		Walk(i, fn)
+	}
+}
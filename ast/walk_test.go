@@ -0,0 +1,46 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalk(t *testing.T) {
+	doc := &ast.Document{Children: []ast.Block{
+		&ast.Heading{Level: 1, Content: []ast.Inline{&ast.Text{Content: "Title"}}},
+		&ast.List{Items: []*ast.ListItem{
+			{
+				Content:  []ast.Inline{&ast.Text{Content: "item"}},
+				Children: []ast.Block{&ast.Image{Token: "img1"}},
+			},
+		}},
+		&ast.Table{Rows: [][]*ast.TableCell{
+			{
+				{Children: []ast.Block{&ast.Image{Token: "img2"}}},
+				nil,
+			},
+		}},
+		&ast.Callout{Children: []ast.Block{&ast.Image{Token: "img3"}}},
+	}}
+
+	var tokens []string
+	ast.Walk(doc, func(n ast.Node) bool {
+		if img, ok := n.(*ast.Image); ok {
+			tokens = append(tokens, img.Token)
+		}
+		return true
+	})
+	assert.Equal(t, []string{"img1", "img2", "img3"}, tokens)
+
+	var texts int
+	ast.Walk(doc, func(n ast.Node) bool {
+		if _, ok := n.(*ast.Text); ok {
+			texts++
+		}
+		_, isList := n.(*ast.List)
+		return !isList
+	})
+	assert.Equal(t, 1, texts)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/pkg/errors"
+)
+
+// readUrlFile reads one URL per line, blank lines and lines starting with
+// # are ignored.
+func readUrlFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	return urls, scanner.Err()
+}
+
+func handleBatchCommand(path string, format string, outputDir string, jobs int) error {
+	urls, err := readUrlFile(path)
+	if err != nil {
+		return err
+	}
+	return handleUrlArguments(urls, format, outputDir, jobs)
+}
+
+// handleUrlArguments downloads the URLs with at most jobs workers, a failed
+// document is reported and the others keep going. The documents are written
+// once all of them are fetched, such that they link to each other locally,
+// and the result of each URL is only known after that.
+func handleUrlArguments(urls []string, format string, outputDir string, jobs int) error {
+	configPath, err := core.GetConfigFilePath()
+	utils.CheckErr(err)
+	config, err := core.ReadConfigFromFile(configPath)
+	utils.CheckErr(err)
+
+	if _, err := core.NewRenderer(format, config.Output); err != nil {
+		return err
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	set := newExportSet(config, format)
+	results := make([]error, len(urls))
+	// the documents prepared for each URL, a folder or wiki has several
+	docs := make([][]*preparedDocument, len(urls))
+	mu := sync.Mutex{}
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				handle := func(
+					ctx context.Context, client *core.Client, url, docToken, outDir, name string,
+				) error {
+					doc, err := set.prepare(ctx, client, url, docToken, outDir, name)
+					if err != nil {
+						return err
+					}
+					mu.Lock()
+					docs[i] = append(docs[i], doc)
+					mu.Unlock()
+					return nil
+				}
+				results[i] = downloadUrlSafely(config, handle, urls[i], outputDir)
+				if results[i] != nil {
+					fmt.Printf("[%d/%d] Failed %s: %s\n", i+1, len(urls), urls[i], results[i])
+				} else {
+					fmt.Printf("[%d/%d] Fetched %s\n", i+1, len(urls), urls[i])
+				}
+			}
+		}()
+	}
+	for i := range urls {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	// the write failures are tied back to the URLs below
+	set.write()
+
+	// a fetched URL only succeeded if all its documents were written
+	failed := 0
+	for i := range urls {
+		if results[i] == nil {
+			for _, doc := range docs[i] {
+				if doc.writeErr != nil {
+					results[i] = errors.Wrapf(doc.writeErr, "Failed to write %s", doc.outPath)
+					break
+				}
+			}
+		}
+		if results[i] != nil {
+			failed++
+			fmt.Printf("[%d/%d] Failed %s: %s\n", i+1, len(urls), urls[i], results[i])
+		} else {
+			fmt.Printf("[%d/%d] Succeeded %s\n", i+1, len(urls), urls[i])
+		}
+	}
+	fmt.Printf("\nDownloaded %d of %d document(s)\n", len(urls)-failed, len(urls))
+	if failed > 0 {
+		return errors.Errorf("%d of %d document(s) failed", failed, len(urls))
+	}
+	return nil
+}
+
+// downloadUrlSafely turns a panic while downloading into an error, one
+// broken document should not take down the whole batch.
+func downloadUrlSafely(config *core.Config, handle documentHandler, url string, outputDir string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("panic: %v", r)
+		}
+	}()
+	return exportUrl(config, url, outputDir, false, handle, &exportReport{})
+}
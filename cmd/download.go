@@ -4,22 +4,93 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 
-	"github.com/88250/lute"
+	"github.com/Wsine/feishu2md/ast"
 	"github.com/Wsine/feishu2md/core"
 	"github.com/Wsine/feishu2md/utils"
 	"github.com/pkg/errors"
 )
 
-func handleUrlArgument(url string) error {
+func handleUrlArgument(url string, format string, outputDir string) error {
 	configPath, err := core.GetConfigFilePath()
 	utils.CheckErr(err)
 	config, err := core.ReadConfigFromFile(configPath)
 	utils.CheckErr(err)
 
-	reg := regexp.MustCompile("^https://[a-zA-Z0-9-]+.(feishu.cn|larksuite.com)/(docx|wiki)/([a-zA-Z0-9]+)")
+	if _, err := core.NewRenderer(format, config.Output); err != nil {
+		return err
+	}
+
+	// the documents fetched before a failure are still written
+	set := newExportSet(config, format)
+	report := &exportReport{}
+	err = exportUrl(config, url, outputDir, false, set.handle, report)
+	if werr := set.write(); werr != nil && err == nil {
+		err = werr
+	}
+	report.print()
+	return err
+}
+
+// documentHandler is called for every docx met while exporting a URL, with
+// the directory and file name (without extension) it should be written to.
+// An empty name falls back to the token or the title of the document.
+type documentHandler func(
+	ctx context.Context, client *core.Client, url, docToken, outDir, name string,
+) error
+
+// exportReport collects the results of walking a wiki or a folder, where a
+// failed document is recorded and the walk goes on. It is printed once the
+// documents are written.
+type exportReport struct {
+	exported int
+	skipped  []string
+	failed   []string
+}
+
+func (r *exportReport) fail(item string, err error) {
+	fmt.Printf("Failed to export %s: %s\n", item, err)
+	r.failed = append(r.failed, fmt.Sprintf("%s: %s", item, err))
+}
+
+// err returns an error if any item of the walk failed
+func (r *exportReport) err() error {
+	if len(r.failed) > 0 {
+		return errors.Errorf("%d item(s) failed to export", len(r.failed))
+	}
+	return nil
+}
+
+// print prints the summary of the walks, nothing if no URL was walked
+func (r *exportReport) print() {
+	if r.exported == 0 && len(r.skipped) == 0 && len(r.failed) == 0 {
+		return
+	}
+	fmt.Printf(
+		"Exported %d document(s), skipped %d item(s), failed %d item(s)\n",
+		r.exported, len(r.skipped), len(r.failed),
+	)
+	for _, item := range r.skipped {
+		fmt.Println("  skipped:", item)
+	}
+	for _, item := range r.failed {
+		fmt.Println("  failed:", item)
+	}
+}
+
+// exportUrl resolves a docx, wiki node, wiki space or drive folder URL to
+// documents and passes each of them to the handler, with the paths under
+// outputDir. The child nodes of a wiki are only visited if recursive is set.
+// The failures of a walk are recorded in the report.
+func exportUrl(
+	config *core.Config, url string, outputDir string, recursive bool,
+	handle documentHandler, report *exportReport,
+) error {
+	reg := regexp.MustCompile("^https://[a-zA-Z0-9-]+.(feishu.cn|larksuite.com)/(docx|wiki/space|wiki|drive/folder)/([a-zA-Z0-9]+)")
 	matchResult := reg.FindStringSubmatch(url)
 	if matchResult == nil || len(matchResult) != 4 {
 		return errors.Errorf("Invalid feishu/larksuite URL format")
@@ -30,51 +101,390 @@ func handleUrlArgument(url string) error {
 	docToken := matchResult[3]
 	fmt.Println("Captured document token:", docToken)
 
-	ctx := context.WithValue(context.Background(), "output", config.Output)
+	ctx := context.Background()
+
+	client := runClient(config, domain)
+
+	switch docType {
+	case "drive/folder":
+		e := &folderExporter{ctx: ctx, client: client, host: urlHost(url), handle: handle, report: report}
+		e.exportFolder(docToken, outputDir)
+		return report.err()
+	case "wiki/space":
+		// a whole space has no document itself, export its top level nodes
+		e := &wikiExporter{ctx: ctx, client: client, host: urlHost(url), recursive: recursive, handle: handle, report: report}
+		e.exportChildren(docToken, "", outputDir)
+		return report.err()
+	case "wiki":
+		node, err := client.GetWikiNodeInfo(ctx, docToken)
+		if err != nil {
+			return err
+		}
+		// a single page keeps the naming of a docx download
+		if !recursive || !node.HasChild {
+			if node.ObjType != "docx" {
+				return errors.Errorf("Unsupported wiki node type: %s", node.ObjType)
+			}
+			return handle(ctx, client, url, node.ObjToken, outputDir, "")
+		}
+		e := &wikiExporter{ctx: ctx, client: client, host: urlHost(url), recursive: recursive, handle: handle, report: report}
+		e.exportNode(&wikiNode{
+			SpaceID:   node.SpaceID,
+			NodeToken: node.NodeToken,
+			ObjToken:  node.ObjToken,
+			ObjType:   node.ObjType,
+			Title:     node.Title,
+			HasChild:  node.HasChild,
+		}, outputDir, make(map[string]bool))
+		return report.err()
+	default:
+		return handle(ctx, client, url, docToken, outputDir, "")
+	}
+}
 
+var (
+	clientsMu sync.Mutex
+	clients   = make(map[string]*core.Client)
+)
+
+// runClient returns the client of a domain shared by all the URLs of the
+// run, such that a user is looked up once even if it fails.
+func runClient(config *core.Config, domain string) *core.Client {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if client, ok := clients[domain]; ok {
+		return client
+	}
 	client := core.NewClient(
 		config.Feishu.AppId, config.Feishu.AppSecret, domain,
+		core.WithRequestConfig(config.Request),
+		core.WithImageCache(newImageCache(config)),
 	)
+	clients[domain] = client
+	return client
+}
 
-	// for a wiki page, we need to renew docType and docToken first
-	if docType == "wiki" {
-		node, err := client.GetWikiNodeInfo(ctx, docToken)
-		utils.CheckErr(err)
-		docType = node.ObjType
-		docToken = node.ObjToken
+// newImageCache returns nil if the image cache is not configured
+func newImageCache(config *core.Config) *core.ImageCache {
+	if config.Output.ImageCacheDir == "" {
+		return nil
+	}
+	return core.NewImageCache(
+		config.Output.ImageCacheDir, config.Output.ImageCacheMaxMB<<20,
+	)
+}
+
+// relativeLink returns the link to target from a file in dir, which stays
+// valid wherever the output directory is. The path is escaped for links.
+func relativeLink(dir, target string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	link, err := filepath.Rel(absDir, absTarget)
+	if err != nil {
+		return "", err
 	}
+	return utils.EscapeLinkPath(filepath.ToSlash(link)), nil
+}
 
-	docx, blocks, err := client.GetDocxContent(ctx, docToken)
-	utils.CheckErr(err)
+// urlHost returns the host of a validated feishu/larksuite URL, which keeps
+// the tenant subdomain dropped by the domain of the client.
+func urlHost(url string) string {
+	return strings.SplitN(strings.TrimPrefix(url, "https://"), "/", 2)[0]
+}
+
+// imageLink returns the link to a downloaded image from outDir. A raw image
+// is embedded as a data URI if configured and small enough, or written to
+// its path otherwise. raw is nil if the image is written already. The second
+// return value reports whether the image is a file at imgPath.
+func imageLink(config *core.Config, outDir, imgPath string, raw []byte) (string, bool, error) {
+	if raw != nil {
+		if config.Output.EmbedImages && core.CanEmbedImage(config.Output, raw) {
+			return core.ImageDataURI(imgPath, raw), false, nil
+		}
+		if err := os.MkdirAll(filepath.Dir(imgPath), 0o755); err != nil {
+			return "", false, err
+		}
+		if err := os.WriteFile(imgPath, raw, 0o644); err != nil {
+			return "", false, err
+		}
+	}
+	link, err := relativeLink(outDir, imgPath)
+	return link, true, err
+}
+
+// documentPath returns where a document is written
+func documentPath(config *core.Config, format, outDir, name, docToken, title string) string {
+	if name == "" {
+		name = docToken
+		if config.Output.TitleAsFilename {
+			name = utils.SanitizeFileName(title, docToken)
+		}
+	}
+	return filepath.Join(outDir, name+core.FormatExtensions[format])
+}
+
+// preparedDocument is a parsed docx with its images downloaded, kept in
+// memory until the whole export is known such that the links between the
+// documents can point at the local files. The assets are the images,
+// attachments and data files written for it.
+type preparedDocument struct {
+	url      string
+	outPath  string
+	document *ast.Document
+	assets   []string
+	written  bool
+	writeErr error
+}
+
+// prepareDocument fetches a docx and downloads its images next to the
+// output path, images are saved such that the links stay valid. An empty
+// name falls back to the token or the title of the document.
+func prepareDocument(
+	ctx context.Context, client *core.Client, config *core.Config,
+	url, docToken, format, outDir, name string,
+) (*preparedDocument, error) {
+	docx, blocks, props, err := client.GetDocxContent(ctx, docToken)
+	if err != nil {
+		return nil, err
+	}
 
-	parser := core.NewParser(ctx)
+	parser := core.NewParser()
+	parser.BlockProps = props
 
 	title := docx.Title
-	markdown := parser.ParseDocxContent(docx, blocks)
+	document := parser.ParseDocxContent(docx, blocks)
+
+	if config.Output.MentionStyle != core.MentionStyleID {
+		if err := client.ResolveUserMentions(ctx, document); err != nil {
+			fmt.Printf("Failed to resolve some user mentions, keeping their ids: %s\n", err)
+		}
+	}
+
+	outPath := documentPath(config, format, outDir, name, docToken, title)
+	var assets []string
+	// saveFile writes a file the document links to, such as an attachment
+	saveFile := func(path string, data []byte) (string, error) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return "", err
+		}
+		assets = append(assets, path)
+		return relativeLink(outDir, path)
+	}
 
 	if !config.Output.SkipImgDownload {
-		for _, imgToken := range parser.ImgTokens {
-			localLink, err := client.DownloadImage(ctx, imgToken, config.Output.ImageDir)
+		localLinks := make(map[string]string)
+		imgDir := config.Output.ImageDir
+		if !filepath.IsAbs(imgDir) {
+			imgDir = filepath.Join(outDir, imgDir)
+		}
+		if config.Output.ImagePerDocDir {
+			imgDir = filepath.Join(imgDir, docx.DocumentID)
+		}
+		naming := core.ImageNaming{Strategy: config.Output.ImageNaming, Title: title}
+		download := client.DownloadImages
+		if config.Output.EmbedImages {
+			download = client.DownloadImagesRaw
+		}
+		downloads, err := download(
+			ctx, parser.ImgTokens, imgDir, naming,
+			config.Output.ImageWorkers, config.Output.SkipFailedImages,
+		)
+		if err != nil {
+			return nil, err
+		}
+		saveImage := func(path string, data []byte) (string, error) {
+			link, saved, err := imageLink(config, outDir, path, data)
+			if saved {
+				assets = append(assets, path)
+			}
+			return link, err
+		}
+		for _, d := range downloads {
+			if d.Err != nil {
+				fmt.Printf("Failed to download image %s: %s\n", d.Token, d.Err)
+				continue
+			}
+			localLink, err := saveImage(d.Path, d.Raw)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			markdown = strings.Replace(markdown, imgToken, localLink, 1)
+			localLinks[d.Token] = localLink
+		}
+		ast.Walk(document, func(n ast.Node) bool {
+			if img, ok := n.(*ast.Image); ok && localLinks[img.Token] != "" {
+				img.Src = localLinks[img.Token]
+			}
+			return true
+		})
+
+		// whiteboards are exported as images, numbered after the others
+		err = client.ExportWhiteboards(
+			ctx, document, docx.RevisionID, len(parser.ImgTokens), imgDir, naming,
+			config.Output.ImageWorkers, saveImage,
+		)
+		if err != nil {
+			fmt.Printf("Failed to export some whiteboards, keeping their placeholders: %s\n", err)
 		}
 	}
+	core.LinkDiagramSources(document, strings.SplitN(url, "#", 2)[0])
 
-	engine := lute.New(func(l *lute.Lute) {
-		l.RenderOptions.AutoSpace = true
-	})
-	result := engine.FormatStr("md", markdown)
+	if !config.Output.SkipFileDownload {
+		fileDir := config.Output.AttachmentDir
+		if !filepath.IsAbs(fileDir) {
+			fileDir = filepath.Join(outDir, fileDir)
+		}
+		if err := client.DownloadFiles(ctx, document, fileDir, saveFile); err != nil {
+			fmt.Printf("Failed to download some files, keeping their tokens: %s\n", err)
+		}
+	}
 
-	mdName := fmt.Sprintf("%s.md", docToken)
-	if config.Output.TitleAsFilename {
-		mdName = fmt.Sprintf("%s.md", title)
+	// large sheets and bitables are saved as <document>-<token>.csv beside it
+	base := strings.TrimSuffix(outPath, filepath.Ext(outPath))
+	err = client.FetchSheets(ctx, document, config.Output.SheetMaxCells, func(sheet *ast.Sheet, data []byte) (string, error) {
+		return saveFile(base+"-"+sheet.Token+".csv", data)
+	})
+	if err != nil {
+		fmt.Printf("Failed to export some sheets, leaving them out: %s\n", err)
+	}
+	err = client.FetchBitables(ctx, document, config.Output.BitableStyle, config.Output.BitableTimeZone, config.Output.SheetMaxCells, func(bitable *ast.Bitable, data []byte, ext string) (string, error) {
+		return saveFile(base+"-"+bitable.Token+ext, data)
+	})
+	if err != nil {
+		fmt.Printf("Failed to export some bitables, leaving them out: %s\n", err)
 	}
-	if err = os.WriteFile(mdName, []byte(result), 0o644); err != nil {
+
+	return &preparedDocument{
+		url:      url,
+		outPath:  outPath,
+		document: document,
+		assets:   assets,
+	}, nil
+}
+
+// writeDocument renders a prepared document to its output path, the links
+// to documents known by resolve are rewritten to relative paths first.
+func writeDocument(
+	config *core.Config, format string, doc *preparedDocument,
+	resolve func(token string) (string, bool),
+) error {
+	renderer, err := core.NewRenderer(format, config.Output)
+	if err != nil {
 		return err
 	}
-	fmt.Printf("Downloaded markdown file to %s\n", mdName)
 
+	core.RewriteDocumentLinks(doc.document, resolve)
+
+	result := renderer.Render(doc.document)
+	if format == core.FormatMarkdown {
+		result = core.PrettifyMarkdown(result, config.Output)
+	}
+	// prepend front matter after formatting, lute does not know TOML blocks
+	if format == core.FormatMarkdown {
+		frontMatter, err := core.NewFrontMatter(doc.document, doc.url).Render(
+			config.Output.FrontMatter, config.Output.FrontMatterTemplate,
+		)
+		if err != nil {
+			return err
+		}
+		result = frontMatter + result
+	}
+
+	if err = os.MkdirAll(filepath.Dir(doc.outPath), 0o755); err != nil {
+		return err
+	}
+	if err = os.WriteFile(doc.outPath, []byte(result), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Downloaded %s file to %s\n", format, doc.outPath)
+	doc.written = true
 	return nil
 }
+
+// exportSet collects the documents of an export, which are only written by
+// write once all of them are known. Links between the documents of the set
+// then point at the local files instead of the cloud.
+type exportSet struct {
+	config *core.Config
+	format string
+
+	mu    sync.Mutex
+	docs  []*preparedDocument
+	paths map[string]string // docx and wiki tokens to output paths
+}
+
+func newExportSet(config *core.Config, format string) *exportSet {
+	return &exportSet{
+		config: config,
+		format: format,
+		paths:  make(map[string]string),
+	}
+}
+
+// handle is the documentHandler preparing the documents of the set
+func (s *exportSet) handle(
+	ctx context.Context, client *core.Client, url, docToken, outDir, name string,
+) error {
+	_, err := s.prepare(ctx, client, url, docToken, outDir, name)
+	return err
+}
+
+func (s *exportSet) prepare(
+	ctx context.Context, client *core.Client, url, docToken, outDir, name string,
+) (*preparedDocument, error) {
+	doc, err := prepareDocument(ctx, client, s.config, url, docToken, s.format, outDir, name)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.docs = append(s.docs, doc)
+	s.mu.Unlock()
+	s.addPath(url, docToken, doc.outPath)
+	return doc, nil
+}
+
+// addPath makes the links to a document point at path, by its docx token
+// and by the wiki token of its URL if any.
+func (s *exportSet) addPath(url, docToken, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paths[docToken] = path
+	if token, ok := core.ParseDocumentToken(url); ok {
+		s.paths[token] = path
+	}
+}
+
+// write writes every prepared document, a failed one is reported and the
+// others keep going. It returns the first failure.
+func (s *exportSet) write() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var firstErr error
+	for _, doc := range s.docs {
+		dir := filepath.Dir(doc.outPath)
+		err := writeDocument(s.config, s.format, doc, func(token string) (string, bool) {
+			path, ok := s.paths[token]
+			if !ok {
+				return "", false
+			}
+			link, err := relativeLink(dir, path)
+			return link, err == nil
+		})
+		doc.writeErr = err
+		if err != nil {
+			fmt.Printf("Failed to write %s: %s\n", doc.outPath, err)
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "Failed to write %s", doc.outPath)
+			}
+		}
+	}
+	return firstErr
+}
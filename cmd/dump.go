@@ -41,7 +41,7 @@ func handleDumpCommand(url string) error {
 		docToken = node.ObjToken
 	}
 
-	docx, blocks, err := client.GetDocxContent(ctx, docToken)
+	docx, blocks, _, err := client.GetDocxContent(ctx, docToken)
 	utils.CheckErr(err)
 
 	data := struct {
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/Wsine/feishu2md/utils"
+)
+
+type folderExporter struct {
+	ctx    context.Context
+	client *core.Client
+	host   string
+	handle documentHandler
+	report *exportReport
+}
+
+// exportFolder exports every docx in the folder into the directory and
+// recurses into the subfolders, other files are only reported. A failed
+// document or subfolder is reported and the others keep going.
+func (e *folderExporter) exportFolder(folderToken, dir string) {
+	files, err := e.client.GetDriveFolderFileList(e.ctx, folderToken)
+	if err != nil {
+		e.report.fail("folder "+folderToken, err)
+		return
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		e.report.fail(dir, err)
+		return
+	}
+
+	usedNames := make(map[string]bool)
+	for _, file := range files {
+		fileType, fileToken := file.Type, file.Token
+		if file.ShortcutInfo != nil {
+			fileType, fileToken = file.ShortcutInfo.TargetType, file.ShortcutInfo.TargetToken
+		}
+
+		name := utils.SanitizeFileName(file.Name, fileToken)
+		if usedNames[name] {
+			name = name + "-" + fileToken
+		}
+		usedNames[name] = true
+
+		switch fileType {
+		case "folder":
+			e.exportFolder(fileToken, filepath.Join(dir, name))
+		case "docx":
+			url := file.URL
+			if url == "" {
+				url = fmt.Sprintf("https://%s/docx/%s", e.host, fileToken)
+			}
+			if err := e.handle(e.ctx, e.client, url, fileToken, dir, name); err != nil {
+				e.report.fail("document "+url, err)
+			} else {
+				e.report.exported++
+			}
+		default:
+			e.report.skipped = append(e.report.skipped, fmt.Sprintf(
+				"%s (%s)", filepath.Join(dir, file.Name), fileType,
+			))
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"os"
 	"strings"
 
+	"github.com/Wsine/feishu2md/core"
+	"github.com/pkg/errors"
 	"github.com/urfave/cli/v2"
 )
 
@@ -15,10 +17,38 @@ func main() {
 		Name:    "feishu2md",
 		Version: strings.TrimSpace(string(version)),
 		Usage:   "download feishu/larksuite document to markdown file",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "format",
+				Value: core.FormatMarkdown,
+				Usage: "Set output format, markdown or html",
+			},
+			&cli.StringFlag{
+				Name:  "output-dir",
+				Value: ".",
+				Usage: "Set the directory the documents are written to",
+			},
+			&cli.IntFlag{
+				Name:  "jobs",
+				Value: 4,
+				Usage: "Set the number of documents downloaded in parallel",
+			},
+		},
 		Action: func(ctx *cli.Context) error {
-			if ctx.NArg() > 0 {
+			err := rejectTrailingFlags(ctx, "feishu2md [--jobs N] <url>...")
+			if err != nil {
+				return err
+			}
+			if ctx.NArg() > 1 {
+				return handleUrlArguments(
+					ctx.Args().Slice(), ctx.String("format"), ctx.String("output-dir"),
+					ctx.Int("jobs"),
+				)
+			} else if ctx.NArg() > 0 {
 				url := ctx.Args().Get(0)
-				return handleUrlArgument(url)
+				return handleUrlArgument(
+					url, ctx.String("format"), ctx.String("output-dir"),
+				)
 			} else {
 				cli.ShowAppHelp(ctx)
 			}
@@ -46,6 +76,101 @@ func main() {
 					)
 				},
 			},
+			{
+				Name:      "wiki",
+				Usage:     "Download a wiki node, or the whole space or subtree",
+				UsageText: "feishu2md wiki <wiki node or space url> [--recursive]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "recursive",
+						Value: false,
+						Usage: "Download the child nodes into nested directories",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					// flags after the url are not parsed, pick a trailing
+					// --recursive up and fail on anything else
+					recursive := ctx.Bool("recursive")
+					var args []string
+					for _, arg := range ctx.Args().Slice() {
+						if arg == "--recursive" || arg == "-recursive" {
+							recursive = true
+						} else {
+							args = append(args, arg)
+						}
+					}
+					if len(args) > 1 {
+						return errors.Errorf(
+							"Unexpected argument(s) %s: feishu2md wiki <url> [--recursive]",
+							strings.Join(args[1:], " "),
+						)
+					}
+					if len(args) > 0 {
+						return handleWikiCommand(
+							args[0], ctx.String("format"), ctx.String("output-dir"),
+							recursive,
+						)
+					} else {
+						cli.ShowCommandHelp(ctx, "wiki")
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "batch",
+				Usage:     "Download the documents listed in a file, one url per line",
+				ArgsUsage: "<url list file>",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "jobs",
+						Value: 4,
+						Usage: "Set the number of documents downloaded in parallel",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					err := rejectTrailingFlags(ctx, "feishu2md batch [--jobs N] <url list file>")
+					if err != nil {
+						return err
+					}
+					if ctx.NArg() > 0 {
+						path := ctx.Args().Get(0)
+						return handleBatchCommand(
+							path, ctx.String("format"), ctx.String("output-dir"),
+							ctx.Int("jobs"),
+						)
+					} else {
+						cli.ShowCommandHelp(ctx, "batch")
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "sync",
+				Usage:     "Download only the documents changed since the last sync",
+				ArgsUsage: "<docx, wiki or folder url>...",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "state",
+						Value: "",
+						Usage: "Set the file recording the synced documents, defaults to .feishu2md-sync.json in the output dir",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					err := rejectTrailingFlags(ctx, "feishu2md sync [--state FILE] <url>...")
+					if err != nil {
+						return err
+					}
+					if ctx.NArg() > 0 {
+						return handleSyncCommand(
+							ctx.Args().Slice(), ctx.String("format"), ctx.String("output-dir"),
+							ctx.String("state"),
+						)
+					} else {
+						cli.ShowCommandHelp(ctx, "sync")
+					}
+					return nil
+				},
+			},
 			{
 				Name:  "dump",
 				Usage: "Dump json response of the OPEN API",
@@ -66,3 +191,14 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// rejectTrailingFlags fails on the flags given after the arguments, which
+// are not parsed and would be taken as arguments otherwise.
+func rejectTrailingFlags(ctx *cli.Context, usage string) error {
+	for _, arg := range ctx.Args().Slice() {
+		if strings.HasPrefix(arg, "-") {
+			return errors.Errorf("Unexpected argument %s, flags must come before the arguments: %s", arg, usage)
+		}
+	}
+	return nil
+}
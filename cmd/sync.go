@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/pkg/errors"
+)
+
+type syncer struct {
+	config    *core.Config
+	format    string
+	state     *core.SyncState
+	set       *exportSet
+	seen      map[string]bool
+	used      map[string]bool // files of the documents seen in this run
+	pending   map[string]*syncedDocument
+	unchanged int
+}
+
+// syncedDocument is a changed document waiting to be written by the set
+type syncedDocument struct {
+	doc        *preparedDocument
+	revisionID int64
+}
+
+// handle prepares the document only if its revision or output path differs
+// from the last sync, an unchanged one is still linked to by the others.
+func (s *syncer) handle(
+	ctx context.Context, client *core.Client, url, docToken, outDir, name string,
+) error {
+	docx, err := client.GetDocxDocument(ctx, docToken)
+	if err != nil {
+		return err
+	}
+	s.seen[docToken] = true
+
+	outPath := documentPath(s.config, s.format, outDir, name, docToken, docx.Title)
+	s.used[outPath] = true
+	if s.state.IsUpToDate(docToken, docx.RevisionID, outPath) {
+		fmt.Printf("Unchanged %s\n", outPath)
+		s.set.addPath(url, docToken, outPath)
+		s.unchanged++
+		return nil
+	}
+
+	doc, err := s.set.prepare(ctx, client, url, docToken, outDir, name)
+	if err != nil {
+		return err
+	}
+	for _, asset := range doc.assets {
+		s.used[asset] = true
+	}
+	s.pending[docToken] = &syncedDocument{doc: doc, revisionID: docx.RevisionID}
+	return nil
+}
+
+// record updates the state with the documents written by the set. It
+// returns the number of them and their records of the last sync, whose
+// files may be stale now.
+func (s *syncer) record() (int, []*core.SyncRecord) {
+	updated := 0
+	var previous []*core.SyncRecord
+	for docToken, p := range s.pending {
+		if !p.doc.written {
+			continue
+		}
+		if record, ok := s.state.Documents[docToken]; ok {
+			previous = append(previous, record)
+		}
+		s.state.Documents[docToken] = &core.SyncRecord{
+			RevisionID: p.revisionID,
+			Path:       p.doc.outPath,
+			URL:        p.doc.url,
+			Assets:     p.doc.assets,
+		}
+		updated++
+	}
+	return updated, previous
+}
+
+// removeStale removes the files and assets of the records, except the ones
+// still used by a recorded document or a document of this run, such as a
+// new document taking the title of a deleted one.
+func (s *syncer) removeStale(records []*core.SyncRecord) {
+	used := s.state.Paths()
+	for path := range s.used {
+		used[path] = true
+	}
+	for _, record := range records {
+		for _, path := range append([]string{record.Path}, record.Assets...) {
+			if !used[path] {
+				removeSyncedFile(path)
+			}
+		}
+	}
+}
+
+func removeSyncedFile(path string) {
+	if err := os.Remove(path); err == nil {
+		fmt.Printf("Removed %s\n", path)
+	} else if !os.IsNotExist(err) {
+		fmt.Printf("Failed to remove %s: %s\n", path, err)
+	}
+}
+
+func handleSyncCommand(urls []string, format string, outputDir string, statePath string) error {
+	configPath, err := core.GetConfigFilePath()
+	utils.CheckErr(err)
+	config, err := core.ReadConfigFromFile(configPath)
+	utils.CheckErr(err)
+
+	if _, err := core.NewRenderer(format, config.Output); err != nil {
+		return err
+	}
+
+	if statePath == "" {
+		statePath = filepath.Join(outputDir, ".feishu2md-sync.json")
+	}
+	state, err := core.ReadSyncStateFromFile(statePath)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to read sync state %s", statePath)
+	}
+
+	s := &syncer{
+		config:  config,
+		format:  format,
+		state:   state,
+		set:     newExportSet(config, format),
+		seen:    make(map[string]bool),
+		used:    make(map[string]bool),
+		pending: make(map[string]*syncedDocument),
+	}
+	report := &exportReport{}
+	for _, url := range urls {
+		if err = exportUrl(config, url, outputDir, true, s.handle, report); err != nil {
+			err = errors.Wrapf(err, "Failed to sync %s", url)
+			break
+		}
+	}
+	// the changed documents are written even if a URL failed, they link to
+	// the unchanged ones through the paths recorded in the set
+	if werr := s.set.write(); werr != nil && err == nil {
+		err = werr
+	}
+	updated, stale := s.record()
+
+	// documents missing from an incomplete listing are not known to be
+	// deleted, so only clean up after every URL went through
+	removed := 0
+	if err == nil {
+		for docToken, record := range state.Documents {
+			if !s.seen[docToken] {
+				stale = append(stale, record)
+				delete(state.Documents, docToken)
+				removed++
+			}
+		}
+	}
+	// only after the state is up to date, which tells the files still used
+	s.removeStale(stale)
+
+	if werr := state.WriteSyncState2File(statePath); werr != nil && err == nil {
+		err = werr
+	}
+	fmt.Printf(
+		"Synced %d updated, %d unchanged, %d removed document(s)\n",
+		updated, s.unchanged, removed,
+	)
+	return err
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/pkg/errors"
+)
+
+// wikiNode is the part of a wiki node needed for the export, shared by the
+// responses of the node info and the node list APIs.
+type wikiNode struct {
+	SpaceID   string
+	NodeToken string
+	ObjToken  string
+	ObjType   string
+	Title     string
+	HasChild  bool
+}
+
+type wikiExporter struct {
+	ctx       context.Context
+	client    *core.Client
+	host      string
+	recursive bool
+	handle    documentHandler
+	report    *exportReport
+}
+
+func handleWikiCommand(url string, format string, outputDir string, recursive bool) error {
+	configPath, err := core.GetConfigFilePath()
+	utils.CheckErr(err)
+	config, err := core.ReadConfigFromFile(configPath)
+	utils.CheckErr(err)
+
+	if _, err := core.NewRenderer(format, config.Output); err != nil {
+		return err
+	}
+
+	reg := regexp.MustCompile("^https://[a-zA-Z0-9-]+.(feishu.cn|larksuite.com)/wiki/")
+	if !reg.MatchString(url) {
+		return errors.Errorf("Invalid feishu/larksuite wiki URL format")
+	}
+
+	// the documents fetched before a failure are still written
+	set := newExportSet(config, format)
+	report := &exportReport{}
+	err = exportUrl(config, url, outputDir, recursive, set.handle, report)
+	if werr := set.write(); werr != nil && err == nil {
+		err = werr
+	}
+	report.print()
+	return err
+}
+
+// exportNode writes a leaf node as <title>.md in the directory, a parent
+// node becomes a <title> directory with its own document as index.md. A
+// failed node is reported and its children are still visited.
+func (e *wikiExporter) exportNode(node *wikiNode, dir string, usedNames map[string]bool) {
+	name := utils.SanitizeFileName(node.Title, node.NodeToken)
+	if usedNames[name] {
+		name = name + "-" + node.NodeToken
+	}
+	usedNames[name] = true
+
+	url := fmt.Sprintf("https://%s/wiki/%s", e.host, node.NodeToken)
+	isParent := e.recursive && node.HasChild
+
+	outDir, outName := dir, name
+	if isParent {
+		outDir, outName = filepath.Join(dir, name), "index"
+	}
+
+	if node.ObjType == "docx" {
+		if err := e.handle(e.ctx, e.client, url, node.ObjToken, outDir, outName); err != nil {
+			e.report.fail("wiki node "+url, err)
+		} else {
+			e.report.exported++
+		}
+	} else {
+		fmt.Printf("Skipped %s node %s, only docx is supported\n", node.ObjType, url)
+	}
+
+	if isParent {
+		e.exportChildren(node.SpaceID, node.NodeToken, outDir)
+	}
+}
+
+func (e *wikiExporter) exportChildren(spaceID, parentNodeToken, dir string) {
+	items, err := e.client.GetWikiNodeList(e.ctx, spaceID, parentNodeToken)
+	if err != nil {
+		e.report.fail("the child nodes of "+dir, err)
+		return
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		e.report.fail(dir, err)
+		return
+	}
+	usedNames := map[string]bool{"index": true}
+	for _, item := range items {
+		node := &wikiNode{
+			SpaceID:   item.SpaceID,
+			NodeToken: item.NodeToken,
+			ObjToken:  item.ObjToken,
+			ObjType:   item.ObjType,
+			Title:     item.Title,
+			HasChild:  item.HasChild,
+		}
+		e.exportNode(node, dir, usedNames)
+	}
+}
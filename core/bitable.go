@@ -0,0 +1,324 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	// the time zones of the bitable dates are looked up on any system
+	_ "time/tzdata"
+
+	"github.com/Wsine/feishu2md/ast"
+	"github.com/chyroc/lark"
+)
+
+// Field types of a bitable whose values need to be told apart from the
+// look of the value alone.
+const (
+	bitableFieldText             = 1
+	bitableFieldDate             = 5
+	bitableFieldLink             = 18
+	bitableFieldDuplexLink       = 21
+	bitableFieldCreatedTime      = 1001
+	bitableFieldLastModifiedTime = 1002
+)
+
+// GetBitableValues returns the records of the bitable embedded in a docx,
+// whose token is <app token>_<table id>. The first row holds the field
+// names and every value is flattened to text, with the dates in loc.
+func (c *Client) GetBitableValues(ctx context.Context, bitableToken string, loc *time.Location) ([][]string, error) {
+	i := strings.LastIndex(bitableToken, "_")
+	if i <= 0 || i == len(bitableToken)-1 {
+		return nil, fmt.Errorf("invalid bitable token: %s", bitableToken)
+	}
+	appToken, tableID := bitableToken[:i], bitableToken[i+1:]
+
+	fields, err := c.getBitableFields(ctx, appToken, tableID)
+	if err != nil {
+		return nil, err
+	}
+	records, err := c.getBitableRecords(ctx, appToken, tableID)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]string, len(fields))
+	for j, field := range fields {
+		header[j] = field.FieldName
+	}
+	rows := [][]string{header}
+
+	// linked records without a text are named after the primary field of
+	// their table, which is fetched once per table
+	linkedNames := make(map[string]map[string]string)
+	for _, record := range records {
+		row := make([]string, len(fields))
+		for j, field := range fields {
+			value := record.Fields[field.FieldName]
+			if field.Type != bitableFieldLink && field.Type != bitableFieldDuplexLink {
+				row[j] = bitableCellText(field.Type, value, loc)
+				continue
+			}
+			link, _ := value.(map[string]interface{})
+			ids, _ := link["link_record_ids"].([]interface{})
+			if text := bitableLinkText(value, nil); text != "" || len(ids) == 0 || field.Property == nil {
+				row[j] = text
+				continue
+			}
+			linkedTableID := field.Property.TableID
+			names, ok := linkedNames[linkedTableID]
+			if !ok {
+				names, err = c.getBitablePrimaryTexts(ctx, appToken, linkedTableID, loc)
+				if err != nil {
+					return nil, err
+				}
+				linkedNames[linkedTableID] = names
+			}
+			row[j] = bitableLinkText(value, names)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (c *Client) getBitableFields(ctx context.Context, appToken, tableID string) ([]*lark.GetBitableFieldListRespItem, error) {
+	var fields []*lark.GetBitableFieldListRespItem
+	var pageToken *string
+	for {
+		var resp *lark.GetBitableFieldListResp
+		err := c.do(ctx, func() (r *lark.Response, err error) {
+			resp, r, err = c.larkClient.Bitable.GetBitableFieldList(ctx, &lark.GetBitableFieldListReq{
+				AppToken:  appToken,
+				TableID:   tableID,
+				PageToken: pageToken,
+			})
+			return r, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, resp.Items...)
+		pageToken = &resp.PageToken
+		if !resp.HasMore {
+			break
+		}
+	}
+	return fields, nil
+}
+
+func (c *Client) getBitableRecords(ctx context.Context, appToken, tableID string) ([]*lark.GetBitableRecordListRespItem, error) {
+	var records []*lark.GetBitableRecordListRespItem
+	var pageToken *string
+	pageSize := int64(100)
+	// the texts of linked records are only returned along with text arrays
+	textAsArray := true
+	for {
+		var resp *lark.GetBitableRecordListResp
+		err := c.do(ctx, func() (r *lark.Response, err error) {
+			resp, r, err = c.larkClient.Bitable.GetBitableRecordList(ctx, &lark.GetBitableRecordListReq{
+				AppToken:         appToken,
+				TableID:          tableID,
+				TextFieldAsArray: &textAsArray,
+				PageToken:        pageToken,
+				PageSize:         &pageSize,
+			})
+			return r, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, resp.Items...)
+		pageToken = &resp.PageToken
+		if !resp.HasMore {
+			break
+		}
+	}
+	return records, nil
+}
+
+// getBitablePrimaryTexts maps the record ids of a table to the text of their
+// primary field, which is always the first field of the table.
+func (c *Client) getBitablePrimaryTexts(ctx context.Context, appToken, tableID string, loc *time.Location) (map[string]string, error) {
+	fields, err := c.getBitableFields(ctx, appToken, tableID)
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+	records, err := c.getBitableRecords(ctx, appToken, tableID)
+	if err != nil {
+		return nil, err
+	}
+	primary := fields[0]
+	texts := make(map[string]string, len(records))
+	for _, record := range records {
+		texts[record.RecordID] = bitableCellText(primary.Type, record.Fields[primary.FieldName], loc)
+	}
+	return texts, nil
+}
+
+// bitableCellText flattens the value of a field to text. Options, persons
+// and attachments are listed by their names, dates are formatted in loc.
+func bitableCellText(fieldType int64, value interface{}, loc *time.Location) string {
+	switch fieldType {
+	case bitableFieldDate, bitableFieldCreatedTime, bitableFieldLastModifiedTime:
+		if ms, ok := value.(float64); ok {
+			t := time.UnixMilli(int64(ms)).In(loc)
+			if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+				return t.Format("2006-01-02")
+			}
+			return t.Format("2006-01-02 15:04")
+		}
+	case bitableFieldText:
+		// rich text is a list of segments to be joined without separator
+		if segments, ok := value.([]interface{}); ok {
+			buf := new(strings.Builder)
+			for _, segment := range segments {
+				buf.WriteString(bitableValueText(segment))
+			}
+			return buf.String()
+		}
+	case bitableFieldLink, bitableFieldDuplexLink:
+		return bitableLinkText(value, nil)
+	}
+	return bitableValueText(value)
+}
+
+// bitableLinkText lists the linked records by the texts the API returns
+// along with them, or else by their names looked up in names. It is empty
+// when a record has neither, rather than showing the record ids.
+func bitableLinkText(value interface{}, names map[string]string) string {
+	link, ok := value.(map[string]interface{})
+	if !ok {
+		return bitableValueText(value)
+	}
+	if text := bitableValueText(link["text_arr"]); text != "" {
+		return text
+	}
+	if text := bitableValueText(link["text"]); text != "" {
+		return text
+	}
+	ids, _ := link["link_record_ids"].([]interface{})
+	texts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		recordID, _ := id.(string)
+		name := names[recordID]
+		if name == "" {
+			return ""
+		}
+		texts = append(texts, name)
+	}
+	return strings.Join(texts, ", ")
+}
+
+func bitableValueText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		texts := make([]string, 0, len(v))
+		for _, item := range v {
+			if text := bitableValueText(item); text != "" {
+				texts = append(texts, text)
+			}
+		}
+		return strings.Join(texts, ", ")
+	case map[string]interface{}:
+		for _, key := range []string{"name", "text", "link", "value"} {
+			if text := bitableValueText(v[key]); text != "" {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+// BitableJSON encodes bitable values as a JSON array of records, keyed by
+// the field names of the first row in their order.
+func BitableJSON(rows [][]string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteString("[")
+	for i, row := range rows {
+		if i == 0 {
+			continue
+		}
+		if i > 1 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  {")
+		for j, value := range row {
+			if j > 0 {
+				buf.WriteString(", ")
+			}
+			key, err := json.Marshal(rows[0][j])
+			if err != nil {
+				return nil, err
+			}
+			text, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(key)
+			buf.WriteString(": ")
+			buf.Write(text)
+		}
+		buf.WriteString("}")
+	}
+	if len(rows) > 1 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+	return buf.Bytes(), nil
+}
+
+// FetchBitables fills the tables of the bitables embedded in the document
+// like FetchSheets, with the dates in the IANA time zone timeZone. With the
+// csv or json style, or above maxCells cells, the records are passed to save
+// with the file extension to use instead.
+func (c *Client) FetchBitables(
+	ctx context.Context, doc *ast.Document, style, timeZone string, maxCells int,
+	save func(bitable *ast.Bitable, data []byte, ext string) (string, error),
+) error {
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return err
+	}
+	var bitables []*ast.Bitable
+	ast.Walk(doc, func(n ast.Node) bool {
+		if bitable, ok := n.(*ast.Bitable); ok {
+			bitables = append(bitables, bitable)
+		}
+		return true
+	})
+
+	var firstErr error
+	for _, bitable := range bitables {
+		rows, err := c.GetBitableValues(ctx, bitable.Token, loc)
+		if err == nil {
+			if style != BitableStyleCSV && style != BitableStyleJSON && SheetCells(rows) <= maxCells {
+				bitable.Table = SheetTable(rows)
+				continue
+			}
+			var data []byte
+			ext := ".csv"
+			if style == BitableStyleJSON {
+				data, err = BitableJSON(rows)
+				ext = ".json"
+			} else {
+				data, err = SheetCSV(rows)
+			}
+			if err == nil {
+				bitable.Src, err = save(bitable, data, ext)
+			}
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
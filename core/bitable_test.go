@@ -0,0 +1,75 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBitableCellText(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	assert.NoError(t, err)
+	// midnight in Shanghai is still the day before in UTC
+	midnight := time.Date(2022, 7, 1, 0, 0, 0, 0, loc).UnixMilli()
+	noon := time.Date(2022, 7, 1, 12, 30, 0, 0, loc).UnixMilli()
+	tests := []struct {
+		name      string
+		fieldType int64
+		value     interface{}
+		want      string
+	}{
+		{"text", 1, "plain", "plain"},
+		{"rich text", 1, []interface{}{
+			map[string]interface{}{"type": "text", "text": "see "},
+			map[string]interface{}{"type": "url", "text": "docs", "link": "https://example.com"},
+		}, "see docs"},
+		{"number", 2, 1.5, "1.5"},
+		{"single select", 3, "high", "high"},
+		{"multi select", 4, []interface{}{"a", "b"}, "a, b"},
+		{"date", 5, float64(midnight), "2022-07-01"},
+		{"date time", 5, float64(noon), "2022-07-01 12:30"},
+		{"checkbox", 7, true, "true"},
+		{"person", 11, []interface{}{
+			map[string]interface{}{"id": "ou_1", "name": "Alice"},
+			map[string]interface{}{"id": "ou_2", "name": "Bob"},
+		}, "Alice, Bob"},
+		{"url", 15, map[string]interface{}{"text": "", "link": "https://example.com"}, "https://example.com"},
+		{"attachment", 17, []interface{}{
+			map[string]interface{}{"file_token": "box1", "name": "spec.pdf"},
+		}, "spec.pdf"},
+		{"link", 18, map[string]interface{}{
+			"link_record_ids": []interface{}{"rec1", "rec2"},
+			"text_arr":        []interface{}{"Task A", "Task B"},
+		}, "Task A, Task B"},
+		{"duplex link", 21, map[string]interface{}{
+			"link_record_ids": []interface{}{"rec1"},
+			"text":            "Task A",
+		}, "Task A"},
+		{"link without text", 18, map[string]interface{}{"link_record_ids": []interface{}{"rec1"}}, ""},
+		{"empty", 1, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, bitableCellText(tt.fieldType, tt.value, loc))
+		})
+	}
+}
+
+func TestBitableLinkText(t *testing.T) {
+	names := map[string]string{"rec1": "Task A", "rec2": "Task B"}
+	link := map[string]interface{}{"link_record_ids": []interface{}{"rec1", "rec2"}}
+	assert.Equal(t, "Task A, Task B", bitableLinkText(link, names))
+	assert.Equal(t, "", bitableLinkText(link, map[string]string{"rec1": "Task A"}))
+	assert.Equal(t, "", bitableLinkText(nil, names))
+}
+
+func TestBitableJSON(t *testing.T) {
+	data, err := BitableJSON([][]string{{"name", "age"}, {"Alice", "30"}, {"Bob", ""}})
+	assert.Nil(t, err)
+	assert.Equal(t, "[\n  {\"name\": \"Alice\", \"age\": \"30\"},\n  {\"name\": \"Bob\", \"age\": \"\"}\n]\n", string(data))
+
+	data, err = BitableJSON([][]string{{"name"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "[]\n", string(data))
+}
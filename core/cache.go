@@ -0,0 +1,166 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ImageCache keeps the downloaded images on disk keyed by their token, the
+// sidecar of every image records its extension and content hash such that
+// a corrupted entry is downloaded again. A nil cache is always missed.
+type ImageCache struct {
+	dir      string
+	maxBytes int64
+	mu       sync.Mutex
+}
+
+type imageCacheEntry struct {
+	Ext    string `json:"ext"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// NewImageCache creates a cache in dir, the least recently used images are
+// evicted once the cache grows over maxBytes, zero means no limit.
+func NewImageCache(dir string, maxBytes int64) *ImageCache {
+	return &ImageCache{dir: dir, maxBytes: maxBytes}
+}
+
+func (c *ImageCache) dataPath(token string) string {
+	return filepath.Join(c.dir, token+".data")
+}
+
+func (c *ImageCache) entryPath(token string) string {
+	return filepath.Join(c.dir, token+".json")
+}
+
+func hashImage(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the file extension and content of a cached image.
+func (c *ImageCache) Get(token string) (string, []byte, bool) {
+	if c == nil {
+		return "", nil, false
+	}
+	file, err := ioutil.ReadFile(c.entryPath(token))
+	if err != nil {
+		return "", nil, false
+	}
+	entry := imageCacheEntry{}
+	if err = json.Unmarshal(file, &entry); err != nil {
+		return "", nil, false
+	}
+	data, err := ioutil.ReadFile(c.dataPath(token))
+	if err != nil || hashImage(data) != entry.SHA256 {
+		c.remove(token)
+		return "", nil, false
+	}
+	// the modification time of the sidecar tracks the last use
+	now := time.Now()
+	os.Chtimes(c.entryPath(token), now, now)
+	return entry.Ext, data, true
+}
+
+// Put stores an image, the content is written before the sidecar so that
+// a partially written entry is never read.
+func (c *ImageCache) Put(token, ext string, data []byte) error {
+	if c == nil {
+		return nil
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.dataPath(token), data); err != nil {
+		return err
+	}
+	entry, err := json.Marshal(imageCacheEntry{
+		Ext:    ext,
+		SHA256: hashImage(data),
+		Size:   int64(len(data)),
+	})
+	if err != nil {
+		return err
+	}
+	if err = writeFileAtomic(c.entryPath(token), entry); err != nil {
+		return err
+	}
+	return c.evict()
+}
+
+func (c *ImageCache) remove(token string) {
+	os.Remove(c.entryPath(token))
+	os.Remove(c.dataPath(token))
+}
+
+// evict removes the least recently used images until the cache fits in
+// maxBytes.
+func (c *ImageCache) evict() error {
+	if c.maxBytes <= 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	type cached struct {
+		token    string
+		size     int64
+		lastUsed time.Time
+	}
+	var entries []cached
+	var total int64
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		token := strings.TrimSuffix(f.Name(), ".json")
+		data, err := os.Stat(c.dataPath(token))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, cached{token, data.Size(), f.ModTime()})
+		total += data.Size()
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastUsed.Before(entries[j].lastUsed)
+	})
+	for _, e := range entries {
+		if total <= c.maxBytes {
+			break
+		}
+		c.remove(e.token)
+		total -= e.size
+	}
+	return nil
+}
+
+func writeFileAtomic(filename string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,57 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageCache(t *testing.T) {
+	dir := t.TempDir()
+	cache := core.NewImageCache(dir, 0)
+
+	_, _, ok := cache.Get("boxcn1")
+	assert.False(t, ok)
+
+	assert.Nil(t, cache.Put("boxcn1", ".png", []byte("image 1")))
+	ext, data, ok := cache.Get("boxcn1")
+	assert.True(t, ok)
+	assert.Equal(t, ".png", ext)
+	assert.Equal(t, []byte("image 1"), data)
+
+	// a corrupted image is dropped
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "boxcn1.data"), []byte("broken"), 0o644))
+	_, _, ok = cache.Get("boxcn1")
+	assert.False(t, ok)
+
+	var nilCache *core.ImageCache
+	assert.Nil(t, nilCache.Put("boxcn1", ".png", []byte("image 1")))
+	_, _, ok = nilCache.Get("boxcn1")
+	assert.False(t, ok)
+}
+
+func TestImageCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	cache := core.NewImageCache(dir, 10)
+
+	assert.Nil(t, cache.Put("old", ".png", []byte("12345")))
+	assert.Nil(t, cache.Put("used", ".png", []byte("12345")))
+	past := time.Now().Add(-time.Hour)
+	assert.Nil(t, os.Chtimes(filepath.Join(dir, "old.json"), past, past))
+	assert.Nil(t, os.Chtimes(filepath.Join(dir, "used.json"), past.Add(time.Minute), past.Add(time.Minute)))
+	_, _, ok := cache.Get("used")
+	assert.True(t, ok)
+
+	// over the limit, the least recently used one goes
+	assert.Nil(t, cache.Put("new", ".png", []byte("12345")))
+	_, _, ok = cache.Get("old")
+	assert.False(t, ok)
+	_, _, ok = cache.Get("used")
+	assert.True(t, ok)
+	_, _, ok = cache.Get("new")
+	assert.True(t, ok)
+}
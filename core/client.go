@@ -3,48 +3,126 @@ package core
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
-	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/Wsine/feishu2md/ast"
+	"github.com/Wsine/feishu2md/utils"
 	"github.com/chyroc/lark"
 )
 
 type Client struct {
-	larkClient *lark.Lark
+	larkClient  *lark.Lark
+	baseURL     string
+	requestConf RequestConfig
+	limiter     *rateLimiter
+	imageCache  *ImageCache
+	users       userCache
 }
 
-func NewClient(appID, appSecret, domain string) *Client {
-	return &Client{
+type ClientOption func(c *Client)
+
+// WithRequestConfig sets the retry and rate limits of the client, the
+// defaults of NewConfig are used otherwise.
+func WithRequestConfig(conf RequestConfig) ClientOption {
+	return func(c *Client) {
+		c.requestConf = conf
+	}
+}
+
+// WithImageCache looks up the images in the cache before downloading them
+func WithImageCache(cache *ImageCache) ClientOption {
+	return func(c *Client) {
+		c.imageCache = cache
+	}
+}
+
+func NewClient(appID, appSecret, domain string, options ...ClientOption) *Client {
+	baseURL := "https://open." + domain
+	c := &Client{
 		larkClient: lark.New(
 			lark.WithAppCredential(appID, appSecret),
-			lark.WithOpenBaseURL("https://open."+domain),
+			lark.WithOpenBaseURL(baseURL),
 			lark.WithTimeout(60*time.Second),
 		),
+		baseURL:     baseURL,
+		requestConf: NewConfig("", "").Request,
 	}
+	for _, option := range options {
+		option(c)
+	}
+	c.limiter = sharedRateLimiter(appID+"@"+domain, c.requestConf)
+	return c
 }
 
-func (c *Client) DownloadImage(ctx context.Context, imgToken, imgDir string) (string, error) {
-	resp, _, err := c.larkClient.Drive.DownloadDriveMedia(ctx, &lark.DownloadDriveMediaReq{
-		FileToken: imgToken,
+// downloadMedia returns the uploaded file name and content of a media of a
+// document, such as an image or an attachment.
+func (c *Client) downloadMedia(ctx context.Context, token string) (string, []byte, error) {
+	var resp *lark.DownloadDriveMediaResp
+	err := c.do(ctx, func() (r *lark.Response, err error) {
+		resp, r, err = c.larkClient.Drive.DownloadDriveMedia(ctx, &lark.DownloadDriveMediaReq{
+			FileToken: token,
+		})
+		return r, err
 	})
 	if err != nil {
-		return imgToken, err
+		return "", nil, err
 	}
-	fileext := filepath.Ext(resp.Filename)
-	filename := fmt.Sprintf("%s/%s%s", imgDir, imgToken, fileext)
-	err = os.MkdirAll(filepath.Dir(filename), 0o755)
+	buf := new(bytes.Buffer)
+	if _, err = buf.ReadFrom(resp.File); err != nil {
+		return "", nil, err
+	}
+	return resp.Filename, buf.Bytes(), nil
+}
+
+// fetchImage returns the file extension and content of an image, from the
+// image cache if there is one.
+func (c *Client) fetchImage(ctx context.Context, imgToken string) (string, []byte, error) {
+	return c.fetchCached(ctx, imgToken, func(ctx context.Context) (string, []byte, error) {
+		filename, data, err := c.downloadMedia(ctx, imgToken)
+		return filepath.Ext(filename), data, err
+	})
+}
+
+// fetchCached looks up an image in the image cache by key, or downloads
+// and caches it. download returns the file extension and content. Caching
+// is best effort, an image that cannot be cached is still returned.
+func (c *Client) fetchCached(
+	ctx context.Context, key string,
+	download func(ctx context.Context) (string, []byte, error),
+) (string, []byte, error) {
+	if fileext, data, ok := c.imageCache.Get(key); ok {
+		return fileext, data, nil
+	}
+	fileext, data, err := download(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if err = c.imageCache.Put(key, fileext, data); err != nil {
+		log.Printf("Failed to cache image %s: %s", key, err)
+	}
+	return fileext, data, nil
+}
+
+func (c *Client) DownloadImage(ctx context.Context, imgToken, imgDir string) (string, error) {
+	return c.downloadImage(ctx, 0, imgToken, imgDir, ImageNaming{})
+}
+
+func (c *Client) downloadImage(ctx context.Context, index int, imgToken, imgDir string, naming ImageNaming) (string, error) {
+	filename, data, err := c.downloadImageRaw(ctx, index, imgToken, imgDir, naming)
 	if err != nil {
 		return imgToken, err
 	}
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, 0o666)
+	err = os.MkdirAll(filepath.Dir(filename), 0o755)
 	if err != nil {
 		return imgToken, err
 	}
-	defer file.Close()
-	_, err = io.Copy(file, resp.File)
+	err = os.WriteFile(filename, data, 0o666)
 	if err != nil {
 		return imgToken, err
 	}
@@ -52,56 +130,320 @@ func (c *Client) DownloadImage(ctx context.Context, imgToken, imgDir string) (st
 }
 
 func (c *Client) DownloadImageRaw(ctx context.Context, imgToken, imgDir string) (string, []byte, error) {
-	resp, _, err := c.larkClient.Drive.DownloadDriveMedia(ctx, &lark.DownloadDriveMediaReq{
-		FileToken: imgToken,
-	})
+	return c.downloadImageRaw(ctx, 0, imgToken, imgDir, ImageNaming{})
+}
+
+func (c *Client) downloadImageRaw(ctx context.Context, index int, imgToken, imgDir string, naming ImageNaming) (string, []byte, error) {
+	fileext, data, err := c.fetchImage(ctx, imgToken)
 	if err != nil {
 		return imgToken, nil, err
 	}
-	fileext := filepath.Ext(resp.Filename)
-	filename := fmt.Sprintf("%s/%s%s", imgDir, imgToken, fileext)
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(resp.File)
-	return filename, buf.Bytes(), nil
+	filename := fmt.Sprintf("%s/%s", imgDir, naming.FileName(index, imgToken, fileext, data))
+	return filename, data, nil
+}
+
+// DownloadFileRaw downloads an attachment in memory, it returns the path
+// <fileDir>/<token>/<name> keeping the name it was uploaded with.
+func (c *Client) DownloadFileRaw(ctx context.Context, fileToken, fileDir string) (string, []byte, error) {
+	name, data, err := c.downloadMedia(ctx, fileToken)
+	if err != nil {
+		return fileToken, nil, err
+	}
+	name = utils.SanitizeFileName(name, fileToken)
+	return fmt.Sprintf("%s/%s/%s", fileDir, fileToken, name), data, nil
+}
+
+// DownloadFiles downloads the attachments of the document and passes each
+// to save, which returns the link the file points to. The attachments that
+// cannot be downloaded keep their token, the first error is returned after
+// trying them all.
+func (c *Client) DownloadFiles(
+	ctx context.Context, doc *ast.Document, fileDir string,
+	save func(path string, data []byte) (string, error),
+) error {
+	var files []*ast.File
+	ast.Walk(doc, func(n ast.Node) bool {
+		if f, ok := n.(*ast.File); ok {
+			files = append(files, f)
+		}
+		return true
+	})
+
+	type download struct {
+		link string
+		size int64
+		err  error
+	}
+	downloads := make(map[string]*download)
+	var firstErr error
+	for _, f := range files {
+		d, ok := downloads[f.Token]
+		if !ok {
+			d = new(download)
+			var filePath string
+			var data []byte
+			filePath, data, d.err = c.DownloadFileRaw(ctx, f.Token, fileDir)
+			if d.err == nil {
+				d.link, d.err = save(filePath, data)
+				d.size = int64(len(data))
+			}
+			if d.err != nil && firstErr == nil {
+				firstErr = d.err
+			}
+			downloads[f.Token] = d
+		}
+		if d.err == nil {
+			f.Src, f.Size = d.link, d.size
+		}
+	}
+	return firstErr
+}
+
+// ImageDownload is the result of downloading one image, Raw is only filled
+// by DownloadImagesRaw.
+type ImageDownload struct {
+	Token string
+	Path  string
+	Raw   []byte
+	Err   error
+}
+
+// DownloadImages downloads the images into imgDir with up to workers
+// downloads at the same time, the results are in the order of imgTokens.
+// The first failure cancels the remaining downloads, unless lenient is set,
+// in which case the failures are only recorded in the results.
+func (c *Client) DownloadImages(ctx context.Context, imgTokens []string, imgDir string, naming ImageNaming, workers int, lenient bool) ([]*ImageDownload, error) {
+	return c.downloadImages(ctx, imgTokens, workers, lenient, func(ctx context.Context, i int, d *ImageDownload) (err error) {
+		d.Path, err = c.downloadImage(ctx, i, d.Token, imgDir, naming)
+		return err
+	})
 }
 
-func (c *Client) GetDocxContent(ctx context.Context, docToken string) (*lark.DocxDocument, []*lark.DocxBlock, error) {
-	resp, _, err := c.larkClient.Drive.GetDocxDocument(ctx, &lark.GetDocxDocumentReq{
-		DocumentID: docToken,
+// DownloadImagesRaw is the same as DownloadImages but keeps the images in
+// memory instead of writing them into imgDir.
+func (c *Client) DownloadImagesRaw(ctx context.Context, imgTokens []string, imgDir string, naming ImageNaming, workers int, lenient bool) ([]*ImageDownload, error) {
+	return c.downloadImages(ctx, imgTokens, workers, lenient, func(ctx context.Context, i int, d *ImageDownload) (err error) {
+		d.Path, d.Raw, err = c.downloadImageRaw(ctx, i, d.Token, imgDir, naming)
+		return err
+	})
+}
+
+func (c *Client) downloadImages(
+	ctx context.Context, imgTokens []string, workers int, lenient bool,
+	download func(ctx context.Context, i int, d *ImageDownload) error,
+) ([]*ImageDownload, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]*ImageDownload, len(imgTokens))
+	indexes := make(chan int)
+	var once sync.Once
+	var firstErr error
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				d := &ImageDownload{Token: imgTokens[i]}
+				d.Err = download(ctx, i, d)
+				results[i] = d
+				if d.Err != nil && !lenient {
+					once.Do(func() {
+						firstErr = d.Err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range imgTokens {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return results, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// GetDocxDocument returns the metadata of a docx only, which is cheap
+// enough to check the revision before downloading the blocks.
+func (c *Client) GetDocxDocument(ctx context.Context, docToken string) (*lark.DocxDocument, error) {
+	var resp *lark.GetDocxDocumentResp
+	err := c.do(ctx, func() (r *lark.Response, err error) {
+		resp, r, err = c.larkClient.Drive.GetDocxDocument(ctx, &lark.GetDocxDocumentReq{
+			DocumentID: docToken,
+		})
+		return r, err
 	})
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	docx := &lark.DocxDocument{
+	return &lark.DocxDocument{
 		DocumentID: resp.Document.DocumentID,
 		RevisionID: resp.Document.RevisionID,
 		Title:      resp.Document.Title,
+	}, nil
+}
+
+// DocxBlockProps are the properties of a block missing from the SDK types,
+// decoded from the same block list.
+type DocxBlockProps struct {
+	Image *DocxImageProps `json:"image,omitempty"`
+	Board *DocxBoardProps `json:"board,omitempty"`
+}
+
+type listDocxBlocksReq struct {
+	DocumentID string  `path:"document_id" json:"-"`
+	PageToken  *string `query:"page_token" json:"-"`
+}
+
+// listDocxBlocksResp keeps the raw items such that they are decoded both as
+// SDK blocks and as DocxBlockProps.
+type listDocxBlocksResp struct {
+	Code int64  `json:"code,omitempty"`
+	Msg  string `json:"msg,omitempty"`
+	Data *struct {
+		Items     []json.RawMessage `json:"items,omitempty"`
+		PageToken string            `json:"page_token,omitempty"`
+		HasMore   bool              `json:"has_more,omitempty"`
+	} `json:"data,omitempty"`
+}
+
+// GetDocxContent returns a document with its blocks, along with the
+// properties of the blocks missing from the SDK types keyed by block id.
+func (c *Client) GetDocxContent(ctx context.Context, docToken string) (*lark.DocxDocument, []*lark.DocxBlock, map[string]*DocxBlockProps, error) {
+	docx, err := c.GetDocxDocument(ctx, docToken)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 	var blocks []*lark.DocxBlock
+	props := make(map[string]*DocxBlockProps)
 	var pageToken *string
 	for {
-		resp2, _, err := c.larkClient.Drive.GetDocxBlockListOfDocument(ctx, &lark.GetDocxBlockListOfDocumentReq{
-			DocumentID: docx.DocumentID,
-			PageToken:  pageToken,
+		resp := new(listDocxBlocksResp)
+		err := c.do(ctx, func() (*lark.Response, error) {
+			return c.larkClient.RawRequest(ctx, &lark.RawRequestReq{
+				Scope:  "Drive",
+				API:    "GetDocxBlockListOfDocument",
+				Method: "GET",
+				URL:    c.baseURL + "/open-apis/docx/v1/documents/:document_id/blocks",
+				Body: &listDocxBlocksReq{
+					DocumentID: docx.DocumentID,
+					PageToken:  pageToken,
+				},
+				NeedTenantAccessToken: true,
+			}, resp)
 		})
 		if err != nil {
-			return docx, nil, err
+			return docx, nil, nil, err
 		}
-		blocks = append(blocks, resp2.Items...)
-		pageToken = &resp2.PageToken
-		if !resp2.HasMore {
+		if resp.Data == nil {
+			break
+		}
+		for _, raw := range resp.Data.Items {
+			block := new(lark.DocxBlock)
+			if err := json.Unmarshal(raw, block); err != nil {
+				return docx, nil, nil, err
+			}
+			blocks = append(blocks, block)
+			p := new(DocxBlockProps)
+			if err := json.Unmarshal(raw, p); err != nil {
+				return docx, nil, nil, err
+			}
+			if p.Image != nil || p.Board != nil {
+				props[block.BlockID] = p
+			}
+		}
+		pageToken = &resp.Data.PageToken
+		if !resp.Data.HasMore {
 			break
 		}
 	}
-	return docx, blocks, nil
+	return docx, blocks, props, nil
 }
 
 func (c *Client) GetWikiNodeInfo(ctx context.Context, token string) (*lark.GetWikiNodeRespNode, error) {
-	resp, _, err := c.larkClient.Drive.GetWikiNode(ctx, &lark.GetWikiNodeReq{
-		Token: token,
+	var resp *lark.GetWikiNodeResp
+	err := c.do(ctx, func() (r *lark.Response, err error) {
+		resp, r, err = c.larkClient.Drive.GetWikiNode(ctx, &lark.GetWikiNodeReq{
+			Token: token,
+		})
+		return r, err
 	})
 	if err != nil {
 		return nil, err
 	}
 	return resp.Node, nil
 }
+
+// GetWikiNodeList lists the child nodes of the parent node, or the top
+// level nodes of the space if parentNodeToken is empty.
+func (c *Client) GetWikiNodeList(ctx context.Context, spaceID, parentNodeToken string) ([]*lark.GetWikiNodeListRespItem, error) {
+	var nodes []*lark.GetWikiNodeListRespItem
+	var pageToken *string
+	for {
+		req := &lark.GetWikiNodeListReq{
+			SpaceID:   spaceID,
+			PageToken: pageToken,
+		}
+		if parentNodeToken != "" {
+			req.ParentNodeToken = &parentNodeToken
+		}
+		var resp *lark.GetWikiNodeListResp
+		err := c.do(ctx, func() (r *lark.Response, err error) {
+			resp, r, err = c.larkClient.Drive.GetWikiNodeList(ctx, req)
+			return r, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, resp.Items...)
+		pageToken = &resp.PageToken
+		if !resp.HasMore {
+			break
+		}
+	}
+	return nodes, nil
+}
+
+// GetDriveFolderFileList lists the files in a drive folder, including the
+// subfolders but not their files.
+func (c *Client) GetDriveFolderFileList(ctx context.Context, folderToken string) ([]*lark.GetDriveFileListRespFile, error) {
+	var files []*lark.GetDriveFileListRespFile
+	var pageToken *string
+	for {
+		var resp *lark.GetDriveFileListResp
+		err := c.do(ctx, func() (r *lark.Response, err error) {
+			resp, r, err = c.larkClient.Drive.GetDriveFileList(ctx, &lark.GetDriveFileListReq{
+				FolderToken: &folderToken,
+				PageToken:   pageToken,
+			})
+			return r, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, resp.Files...)
+		pageToken = &resp.NextPageToken
+		if !resp.HasMore {
+			break
+		}
+	}
+	return files, nil
+}
@@ -46,10 +46,37 @@ func TestDownloadImage(t *testing.T) {
 	}
 }
 
+func TestDownloadImages(t *testing.T) {
+	appID, appSecret := getIdAndSecretFromEnv()
+	c := core.NewClient(appID, appSecret, "feishu.cn")
+	imgTokens := []string{"boxcnA1QKPanfMhLxzF1eMhoArM", "invalidtoken"}
+
+	// lenient mode keeps the order and records the failure
+	downloads, err := c.DownloadImages(context.Background(), imgTokens, "static", core.ImageNaming{}, 2, true)
+	if err != nil {
+		t.Error(err)
+	}
+	if downloads[0].Token != imgTokens[0] || downloads[0].Err != nil {
+		t.Errorf("Error: first image not downloaded")
+	}
+	if downloads[1].Token != imgTokens[1] || downloads[1].Err == nil {
+		t.Errorf("Error: invalid image not reported")
+	}
+
+	// strict mode fails the whole download
+	_, err = c.DownloadImages(context.Background(), imgTokens, "static", core.ImageNaming{}, 2, false)
+	if err == nil {
+		t.Errorf("Error: invalid image not failing")
+	}
+	if err := os.RemoveAll("static"); err != nil {
+		t.Errorf("Error: failed to clean up the folder")
+	}
+}
+
 func TestGetDocxContent(t *testing.T) {
 	appID, appSecret := getIdAndSecretFromEnv()
 	c := core.NewClient(appID, appSecret, "feishu.cn")
-	docx, blocks, err := c.GetDocxContent(
+	docx, blocks, _, err := c.GetDocxContent(
 		context.Background(),
 		"doxcnXhd93zqoLnmVPGIPTy7AFe",
 	)
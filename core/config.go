@@ -9,8 +9,9 @@ import (
 )
 
 type Config struct {
-	Feishu FeishuConfig `json:"feishu"`
-	Output OutputConfig `json:"output"`
+	Feishu  FeishuConfig  `json:"feishu"`
+	Output  OutputConfig  `json:"output"`
+	Request RequestConfig `json:"request"`
 }
 
 type FeishuConfig struct {
@@ -18,13 +19,113 @@ type FeishuConfig struct {
 	AppSecret string `json:"app_secret"`
 }
 
+// RequestConfig limits the requests to the OPEN API, RateLimit is in
+// requests per second and zero disables the limit
+type RequestConfig struct {
+	MaxRetries  int     `json:"max_retries"`
+	BaseDelayMs int     `json:"base_delay_ms"`
+	MaxDelayMs  int     `json:"max_delay_ms"`
+	RateLimit   float64 `json:"rate_limit"`
+	RateBurst   int     `json:"rate_burst"`
+}
+
 type OutputConfig struct {
-	ImageDir        string `json:"image_dir"`
-	TitleAsFilename bool   `json:"title_as_filename"`
-	UseHTMLTags     bool   `json:"use_html_tags"`
-	SkipImgDownload bool   `json:"skip_img_download"`
+	ImageDir         string `json:"image_dir"`
+	TitleAsFilename  bool   `json:"title_as_filename"`
+	UseHTMLTags      bool   `json:"use_html_tags"`
+	SkipImgDownload  bool   `json:"skip_img_download"`
+	ImageWorkers     int    `json:"image_workers"`
+	SkipFailedImages bool   `json:"skip_failed_images"`
+	ImageNaming      string `json:"image_naming"`
+	ImagePerDocDir   bool   `json:"image_per_doc_dir"`
+	EmbedImages      bool   `json:"embed_images"`
+	EmbedImageMaxKB  int64  `json:"embed_image_max_kb"`
+	ImageStyle       string `json:"image_style"`
+	MentionStyle     string `json:"mention_style"`
+	AttachmentDir    string `json:"attachment_dir"`
+	SkipFileDownload bool   `json:"skip_file_download"`
+	ImageCacheDir    string `json:"image_cache_dir"`
+	ImageCacheMaxMB  int64  `json:"image_cache_max_mb"`
+	CalloutStyle     string `json:"callout_style"`
+	GridStyle        string `json:"grid_style"`
+	TableStyle       string `json:"table_style"`
+	// SheetMaxCells is the largest embedded sheet or bitable rendered as a
+	// table, the larger ones are saved as a CSV file next to the document
+	SheetMaxCells int    `json:"sheet_max_cells"`
+	BitableStyle  string `json:"bitable_style"`
+	// BitableTimeZone is the IANA time zone the bitable dates are written
+	// in, Feishu stores them as UTC timestamps
+	BitableTimeZone string `json:"bitable_time_zone"`
+	FrontMatter     string `json:"front_matter"`
+	// FrontMatterTemplate adds custom fields to the front matter, every
+	// value is a text/template executed against the document metadata
+	FrontMatterTemplate map[string]string `json:"front_matter_template"`
 }
 
+// Supported values of OutputConfig.ImageNaming, title names the images as
+// <doc-title>-<n> in the order they appear in the document
+const (
+	ImageNamingToken  = "token"
+	ImageNamingSHA256 = "sha256"
+	ImageNamingTitle  = "title"
+)
+
+// Supported values of OutputConfig.ImageStyle, html and pandoc keep the
+// width and height of the image, only html keeps its alignment. The caption
+// is the alt text in every style.
+const (
+	ImageStyleMarkdown = "markdown"
+	ImageStyleHTML     = "html"
+	ImageStylePandoc   = "pandoc"
+)
+
+// Supported values of OutputConfig.MentionStyle, name and mailto look up
+// the users through the contact API and fall back to the id
+const (
+	MentionStyleID     = "id"
+	MentionStyleName   = "name"
+	MentionStyleMailto = "mailto"
+)
+
+// Supported values of OutputConfig.CalloutStyle
+const (
+	CalloutStyleBlockquote = "blockquote"
+	CalloutStyleGitHub     = "github"
+	CalloutStyleMkDocs     = "mkdocs"
+	CalloutStyleDocusaurus = "docusaurus"
+)
+
+// Supported values of OutputConfig.GridStyle
+const (
+	GridStyleFlatten = "flatten"
+	GridStyleHTML    = "html"
+)
+
+// Supported values of OutputConfig.TableStyle, auto picks HTML for tables
+// with merged cells or with cells holding other blocks than paragraphs,
+// lists, images and files
+const (
+	TableStyleAuto     = "auto"
+	TableStyleMarkdown = "markdown"
+	TableStyleHTML     = "html"
+)
+
+// Supported values of OutputConfig.BitableStyle, csv and json always save
+// the records next to the document and link to them
+const (
+	BitableStyleTable = "table"
+	BitableStyleCSV   = "csv"
+	BitableStyleJSON  = "json"
+)
+
+// Supported values of OutputConfig.FrontMatter, none disables it
+const (
+	FrontMatterNone = ""
+	FrontMatterYAML = "yaml"
+	FrontMatterTOML = "toml"
+	FrontMatterJSON = "json"
+)
+
 func NewConfig(appId, appSecret string) *Config {
 	return &Config{
 		Feishu: FeishuConfig{
@@ -32,10 +133,36 @@ func NewConfig(appId, appSecret string) *Config {
 			AppSecret: appSecret,
 		},
 		Output: OutputConfig{
-			ImageDir:        "static",
-			TitleAsFilename: false,
-			UseHTMLTags:     false,
-			SkipImgDownload: false,
+			ImageDir:         "static",
+			TitleAsFilename:  false,
+			UseHTMLTags:      false,
+			SkipImgDownload:  false,
+			ImageWorkers:     4,
+			SkipFailedImages: false,
+			ImageNaming:      ImageNamingToken,
+			ImagePerDocDir:   false,
+			EmbedImages:      false,
+			EmbedImageMaxKB:  512,
+			ImageStyle:       ImageStyleMarkdown,
+			MentionStyle:     MentionStyleID,
+			AttachmentDir:    "attachments",
+			SkipFileDownload: false,
+			ImageCacheDir:    "",
+			ImageCacheMaxMB:  0,
+			CalloutStyle:     CalloutStyleBlockquote,
+			GridStyle:        GridStyleFlatten,
+			TableStyle:       TableStyleAuto,
+			SheetMaxCells:    500,
+			BitableStyle:     BitableStyleTable,
+			BitableTimeZone:  "Asia/Shanghai",
+			FrontMatter:      FrontMatterNone,
+		},
+		Request: RequestConfig{
+			MaxRetries:  5,
+			BaseDelayMs: 500,
+			MaxDelayMs:  30000,
+			RateLimit:   5,
+			RateBurst:   5,
 		},
 	}
 }
@@ -54,12 +181,14 @@ func ReadConfigFromFile(configPath string) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	config := Config{}
-	err = json.Unmarshal([]byte(file), &config)
+	// start from the defaults such that options missing in an older
+	// config file keep working
+	config := NewConfig("", "")
+	err = json.Unmarshal([]byte(file), config)
 	if err != nil {
 		return nil, err
 	}
-	return &config, nil
+	return config, nil
 }
 
 func (conf *Config) WriteConfig2File(configPath string) error {
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/Wsine/feishu2md/ast"
+	"github.com/chyroc/lark"
+)
+
+// docxBlockTypeBoard is the whiteboard block, newer than the SDK
+const docxBlockTypeBoard lark.DocxBlockType = 43
+
+// DocxBoardProps are the properties of a board block, missing from the SDK
+type DocxBoardProps struct {
+	Token string `json:"token,omitempty"`
+}
+
+type downloadWhiteboardReq struct {
+	WhiteboardID string `path:"whiteboard_id" json:"-"`
+}
+
+// downloadWhiteboardResp receives the image through the SetReader hook of
+// the SDK, or the error as JSON.
+type downloadWhiteboardResp struct {
+	Code int64  `json:"code,omitempty"`
+	Msg  string `json:"msg,omitempty"`
+	file io.Reader
+}
+
+func (r *downloadWhiteboardResp) SetReader(file io.Reader) {
+	r.file = file
+}
+
+// ExportWhiteboards exports the whiteboards of the document as PNG images
+// with up to workers exports at the same time, named like the images in
+// imgDir and numbered from firstIndex. Each image is passed to save, which
+// returns the link the whiteboard points to. The whiteboards that cannot be
+// exported keep their placeholder, the first error is returned after
+// trying them all.
+func (c *Client) ExportWhiteboards(
+	ctx context.Context, doc *ast.Document, revision int64,
+	firstIndex int, imgDir string, naming ImageNaming, workers int,
+	save func(path string, data []byte) (string, error),
+) error {
+	var diagrams []*ast.Diagram
+	var tokens []string
+	ast.Walk(doc, func(n ast.Node) bool {
+		if d, ok := n.(*ast.Diagram); ok && d.Kind == ast.DiagramKindWhiteboard && d.Token != "" {
+			diagrams = append(diagrams, d)
+			tokens = append(tokens, d.Token)
+		}
+		return true
+	})
+
+	exports, err := c.downloadWhiteboards(ctx, revision, tokens, firstIndex, imgDir, naming, workers)
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for i, e := range exports {
+		err := e.Err
+		if err == nil {
+			diagrams[i].Src, err = save(e.Path, e.Raw)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// downloadWhiteboards is DownloadImagesRaw in lenient mode for whiteboards.
+// A whiteboard keeps its token when edited, so the images are cached per
+// revision of the document.
+func (c *Client) downloadWhiteboards(
+	ctx context.Context, revision int64, tokens []string,
+	firstIndex int, imgDir string, naming ImageNaming, workers int,
+) ([]*ImageDownload, error) {
+	return c.downloadImages(ctx, tokens, workers, true, func(ctx context.Context, i int, d *ImageDownload) error {
+		key := fmt.Sprintf("%s-%d", d.Token, revision)
+		fileext, data, err := c.fetchCached(ctx, key, func(ctx context.Context) (string, []byte, error) {
+			data, err := c.exportWhiteboard(ctx, d.Token)
+			return ".png", data, err
+		})
+		if err != nil {
+			return err
+		}
+		d.Path = fmt.Sprintf("%s/%s", imgDir, naming.FileName(firstIndex+i, d.Token, fileext, data))
+		d.Raw = data
+		return nil
+	})
+}
+
+// exportWhiteboard returns a whiteboard rendered as a PNG image
+func (c *Client) exportWhiteboard(ctx context.Context, token string) ([]byte, error) {
+	resp := new(downloadWhiteboardResp)
+	err := c.do(ctx, func() (*lark.Response, error) {
+		return c.larkClient.RawRequest(ctx, &lark.RawRequestReq{
+			Scope:                 "Board",
+			API:                   "DownloadWhiteboardAsImage",
+			Method:                "GET",
+			URL:                   c.baseURL + "/open-apis/board/v1/whiteboards/:whiteboard_id/download_as_image",
+			Body:                  &downloadWhiteboardReq{WhiteboardID: token},
+			NeedTenantAccessToken: true,
+		}, resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.file == nil {
+		return nil, fmt.Errorf("no image exported for whiteboard %s", token)
+	}
+	buf := new(bytes.Buffer)
+	if _, err = buf.ReadFrom(resp.file); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+	// an error answered with 200 is not caught by the SDK
+	if bytes.HasPrefix(data, []byte("{")) {
+		if err = json.Unmarshal(data, resp); err == nil && resp.Code != 0 {
+			return nil, lark.NewError("Board", "DownloadWhiteboardAsImage", resp.Code, resp.Msg)
+		}
+	}
+	return data, nil
+}
+
+var diagramLabels = map[string]string{
+	ast.DiagramKindDiagram:    "Diagram",
+	ast.DiagramKindWhiteboard: "Whiteboard",
+	ast.DiagramKindMindnote:   "Mindnote",
+}
+
+// diagramPlaceholder is the text standing for a diagram not exported
+func diagramPlaceholder(d *ast.Diagram) string {
+	return diagramLabels[d.Kind] + " (not exported)"
+}
+
+// LinkDiagramSources points the diagrams of the document back to their
+// blocks in the document at url, for the ones without an exported image.
+func LinkDiagramSources(doc *ast.Document, url string) {
+	ast.Walk(doc, func(n ast.Node) bool {
+		if d, ok := n.(*ast.Diagram); ok {
+			d.URL = url + "#" + d.BlockID
+		}
+		return true
+	})
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Wsine/feishu2md/ast"
+	"github.com/pkg/errors"
+)
+
+// FrontMatter is the metadata of an exported document, it is also the data
+// passed to the user templates in OutputConfig.FrontMatterTemplate.
+type FrontMatter struct {
+	Title      string
+	DocumentID string
+	RevisionID int64
+	URL        string
+	Date       time.Time
+}
+
+func NewFrontMatter(doc *ast.Document, url string) FrontMatter {
+	return FrontMatter{
+		Title:      doc.Title,
+		DocumentID: doc.ID,
+		RevisionID: doc.RevisionID,
+		URL:        url,
+		Date:       time.Now(),
+	}
+}
+
+type frontMatterField struct {
+	key   string
+	value string // already encoded
+}
+
+// encodeFrontMatterString quotes a string in a way that is valid in YAML,
+// TOML and JSON alike.
+func encodeFrontMatterString(s string) string {
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+var bareFrontMatterKey = regexp.MustCompile("^[A-Za-z0-9_-]+$")
+
+func encodeFrontMatterKey(key string) string {
+	if bareFrontMatterKey.MatchString(key) {
+		return key
+	}
+	return encodeFrontMatterString(key)
+}
+
+// frontMatterNumber matches the integers and decimals written the same in
+// YAML, TOML and JSON. A decimal ending in 0 would lose it once parsed.
+var frontMatterNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]*[1-9])?$`)
+
+// encodeFrontMatterValue keeps booleans and numbers from the templates
+// unquoted, such that `draft: false` works as expected. Anything else, such
+// as "t", "NaN" or "0x10", is quoted.
+func encodeFrontMatterValue(s string) string {
+	if s == "true" || s == "false" || frontMatterNumber.MatchString(s) {
+		return s
+	}
+	return encodeFrontMatterString(s)
+}
+
+func (fm FrontMatter) fields(templates map[string]string) ([]frontMatterField, error) {
+	fields := []frontMatterField{
+		{"title", encodeFrontMatterString(fm.Title)},
+		{"document_id", encodeFrontMatterString(fm.DocumentID)},
+		{"revision_id", strconv.FormatInt(fm.RevisionID, 10)},
+		{"url", encodeFrontMatterString(fm.URL)},
+		{"date", encodeFrontMatterString(fm.Date.Format(time.RFC3339))},
+	}
+
+	keys := make([]string, 0, len(templates))
+	for key := range templates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		tmpl, err := template.New(key).Parse(templates[key])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid front matter template of %s", key)
+		}
+		buf := new(strings.Builder)
+		if err = tmpl.Execute(buf, fm); err != nil {
+			return nil, errors.Wrapf(err, "Failed to execute front matter template of %s", key)
+		}
+		field := frontMatterField{key, encodeFrontMatterValue(buf.String())}
+
+		// custom fields are allowed to override the builtin ones
+		overridden := false
+		for i := range fields {
+			if fields[i].key == key {
+				fields[i] = field
+				overridden = true
+			}
+		}
+		if !overridden {
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+// Render returns the front matter block in the given style, ends with a
+// blank line such that it can be prepended to the document directly.
+func (fm FrontMatter) Render(style string, templates map[string]string) (string, error) {
+	if style == FrontMatterNone {
+		return "", nil
+	}
+	fields, err := fm.fields(templates)
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(strings.Builder)
+	switch style {
+	case FrontMatterYAML:
+		buf.WriteString("---\n")
+		for _, f := range fields {
+			buf.WriteString(fmt.Sprintf("%s: %s\n", encodeFrontMatterKey(f.key), f.value))
+		}
+		buf.WriteString("---\n")
+	case FrontMatterTOML:
+		buf.WriteString("+++\n")
+		for _, f := range fields {
+			buf.WriteString(fmt.Sprintf("%s = %s\n", encodeFrontMatterKey(f.key), f.value))
+		}
+		buf.WriteString("+++\n")
+	case FrontMatterJSON:
+		buf.WriteString("{\n")
+		for i, f := range fields {
+			buf.WriteString(fmt.Sprintf("  %s: %s", encodeFrontMatterString(f.key), f.value))
+			if i < len(fields)-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString("}\n")
+	default:
+		return "", errors.Errorf("Unsupported front matter style: %s", style)
+	}
+	buf.WriteString("\n")
+	return buf.String(), nil
+}
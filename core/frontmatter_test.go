@@ -0,0 +1,100 @@
+package core_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrontMatterRender(t *testing.T) {
+	fm := core.FrontMatter{
+		Title:      `Say "hi"`,
+		DocumentID: "doxcn123",
+		RevisionID: 42,
+		URL:        "https://domain.feishu.cn/docx/doxcn123",
+		Date:       time.Date(2023, 3, 1, 8, 0, 0, 0, time.UTC),
+	}
+	templates := map[string]string{
+		"draft": "false",
+		"slug":  "posts/{{.DocumentID}}",
+	}
+
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{core.FrontMatterNone, ""},
+		{core.FrontMatterYAML, "---\n" +
+			"title: \"Say \\\"hi\\\"\"\n" +
+			"document_id: \"doxcn123\"\n" +
+			"revision_id: 42\n" +
+			"url: \"https://domain.feishu.cn/docx/doxcn123\"\n" +
+			"date: \"2023-03-01T08:00:00Z\"\n" +
+			"draft: false\n" +
+			"slug: \"posts/doxcn123\"\n" +
+			"---\n\n"},
+		{core.FrontMatterTOML, "+++\n" +
+			"title = \"Say \\\"hi\\\"\"\n" +
+			"document_id = \"doxcn123\"\n" +
+			"revision_id = 42\n" +
+			"url = \"https://domain.feishu.cn/docx/doxcn123\"\n" +
+			"date = \"2023-03-01T08:00:00Z\"\n" +
+			"draft = false\n" +
+			"slug = \"posts/doxcn123\"\n" +
+			"+++\n\n"},
+		{core.FrontMatterJSON, "{\n" +
+			"  \"title\": \"Say \\\"hi\\\"\",\n" +
+			"  \"document_id\": \"doxcn123\",\n" +
+			"  \"revision_id\": 42,\n" +
+			"  \"url\": \"https://domain.feishu.cn/docx/doxcn123\",\n" +
+			"  \"date\": \"2023-03-01T08:00:00Z\",\n" +
+			"  \"draft\": false,\n" +
+			"  \"slug\": \"posts/doxcn123\"\n" +
+			"}\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			got, err := fm.Render(tt.style, templates)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := fm.Render(core.FrontMatterYAML, map[string]string{"bad": "{{.Missing"})
+	assert.NotNil(t, err)
+}
+
+func TestFrontMatterRenderScalars(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"true", "true"},
+		{"false", "false"},
+		{"42", "42"},
+		{"-3.25", "-3.25"},
+		{"0", "0"},
+		{"t", `"t"`},
+		{"F", `"F"`},
+		{"TRUE", `"TRUE"`},
+		{"NaN", `"NaN"`},
+		{"Inf", `"Inf"`},
+		{".5", `".5"`},
+		{"01", `"01"`},
+		{"0x10", `"0x10"`},
+		{"1e3", `"1e3"`},
+		{"1.10", `"1.10"`},
+		{"1.", `"1."`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := core.FrontMatter{}.Render(core.FrontMatterJSON, map[string]string{"title": tt.value})
+			assert.Nil(t, err)
+			assert.True(t, json.Valid([]byte(got)))
+			assert.Contains(t, got, "\"title\": "+tt.want+",\n")
+		})
+	}
+}
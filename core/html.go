@@ -0,0 +1,477 @@
+package core
+
+import (
+	"fmt"
+	"html"
+	"path"
+	"strings"
+
+	"github.com/Wsine/feishu2md/ast"
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/alecthomas/chroma"
+	chromahtml "github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+)
+
+type HTMLRenderer struct {
+	conf      OutputConfig
+	formatter *chromahtml.Formatter
+	style     *chroma.Style
+}
+
+func NewHTMLRenderer(conf OutputConfig) *HTMLRenderer {
+	return &HTMLRenderer{
+		conf:      conf,
+		formatter: chromahtml.New(chromahtml.WithClasses(true), chromahtml.TabWidth(4)),
+		style:     styles.Get("github"),
+	}
+}
+
+// =============================================================
+// Renderer utils
+// =============================================================
+
+const htmlStylesheet = `body {
+  margin: 0;
+  color: #1f2329;
+  font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, "PingFang SC", "Microsoft YaHei", sans-serif;
+  line-height: 1.7;
+}
+article {
+  max-width: 860px;
+  margin: 0 auto;
+  padding: 32px 16px;
+}
+h1, h2, h3, h4, h5, h6 {
+  margin: 1.4em 0 0.6em;
+  line-height: 1.3;
+}
+a {
+  color: #3370ff;
+  text-decoration: none;
+}
+img {
+  max-width: 100%;
+  height: auto;
+}
+code {
+  padding: 0.1em 0.3em;
+  border-radius: 4px;
+  background: #f2f3f5;
+  font-family: SFMono-Regular, Consolas, Menlo, monospace;
+  font-size: 0.9em;
+}
+pre {
+  padding: 12px 16px;
+  border-radius: 6px;
+  background: #f5f6f7;
+  overflow-x: auto;
+}
+pre code {
+  padding: 0;
+  background: none;
+}
+blockquote {
+  margin: 1em 0;
+  padding: 0 1em;
+  border-left: 4px solid #dee0e3;
+  color: #646a73;
+}
+table {
+  border-collapse: collapse;
+  margin: 1em 0;
+}
+th, td {
+  padding: 6px 12px;
+  border: 1px solid #dee0e3;
+}
+th {
+  background: #f5f6f7;
+}
+hr {
+  border: none;
+  border-top: 1px solid #dee0e3;
+}
+ul.task-list {
+  padding-left: 1.2em;
+  list-style: none;
+}
+.callout {
+  margin: 1em 0;
+  padding: 12px 16px;
+  border-radius: 8px;
+}
+.callout-emoji {
+  float: left;
+  margin-right: 8px;
+}
+.grid {
+  display: flex;
+  gap: 16px;
+}
+.math {
+  overflow-x: auto;
+}
+`
+
+var calloutColor2CSS = map[string]string{
+	"light-red":    "#fef1f1",
+	"light-orange": "#fef4e6",
+	"light-yellow": "#fefbe6",
+	"light-green":  "#effaf2",
+	"light-blue":   "#f0f4ff",
+	"light-purple": "#f6f1fe",
+	"light-grey":   "#f5f6f7",
+	"dark-red":     "#fbbfbc",
+	"dark-orange":  "#fed4a4",
+	"dark-yellow":  "#fff67a",
+	"dark-green":   "#b7edb1",
+	"dark-blue":    "#bacefd",
+	"dark-purple":  "#cdb2fa",
+	"dark-grey":    "#dee0e3",
+}
+
+// plainText drops the styles of the inlines, links keep their target.
+func plainText(inlines []ast.Inline) string {
+	buf := new(strings.Builder)
+	for _, inline := range inlines {
+		switch i := inline.(type) {
+		case *ast.Text:
+			buf.WriteString(i.Content)
+		case *ast.UserMention:
+			buf.WriteString(i.UserID)
+		case *ast.DocMention:
+			buf.WriteString(i.URL)
+		case *ast.InlineMath:
+			buf.WriteString(i.Content)
+		}
+	}
+	return buf.String()
+}
+
+// =============================================================
+// Render the document tree to a standalone HTML page
+// =============================================================
+
+func (r *HTMLRenderer) Render(doc *ast.Document) string {
+	css := new(strings.Builder)
+	css.WriteString(htmlStylesheet)
+	r.formatter.WriteCSS(css, r.style)
+
+	buf := new(strings.Builder)
+	buf.WriteString("<!DOCTYPE html>\n<html>\n<head>\n")
+	buf.WriteString("<meta charset=\"utf-8\">\n")
+	buf.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	buf.WriteString("<title>" + html.EscapeString(doc.Title) + "</title>\n")
+	buf.WriteString("<style>\n" + css.String() + "</style>\n")
+	buf.WriteString("</head>\n<body>\n<article>\n")
+	buf.WriteString(r.RenderBlocks(doc.Children))
+	buf.WriteString("</article>\n</body>\n</html>\n")
+	return buf.String()
+}
+
+func (r *HTMLRenderer) RenderBlocks(blocks []ast.Block) string {
+	buf := new(strings.Builder)
+	for _, b := range blocks {
+		buf.WriteString(r.RenderBlock(b))
+	}
+	return buf.String()
+}
+
+func (r *HTMLRenderer) RenderBlock(b ast.Block) string {
+	buf := new(strings.Builder)
+	switch b := b.(type) {
+	case *ast.Paragraph:
+		if len(b.Content) > 0 {
+			buf.WriteString("<p>" + r.RenderInlines(b.Content) + "</p>\n")
+		}
+	case *ast.Heading:
+		level := b.Level
+		if level > 6 {
+			level = 6
+		}
+		buf.WriteString(fmt.Sprintf("<h%d>%s</h%d>\n", level, r.RenderInlines(b.Content), level))
+	case *ast.List:
+		buf.WriteString(r.RenderList(b))
+	case *ast.Code:
+		buf.WriteString(r.RenderCode(b))
+	case *ast.Math:
+		buf.WriteString("<div class=\"math\">\\[" + html.EscapeString(b.Content) + "\\]</div>\n")
+	case *ast.Blockquote:
+		buf.WriteString("<blockquote>\n" + r.RenderBlocks(b.Children) + "</blockquote>\n")
+	case *ast.ThematicBreak:
+		buf.WriteString("<hr>\n")
+	case *ast.Image:
+		buf.WriteString(r.renderImageBlock(b))
+	case *ast.File:
+		buf.WriteString("<p>" + r.RenderFile(b) + "</p>\n")
+	case *ast.Iframe:
+		buf.WriteString(iframeTag(b) + "\n")
+	case *ast.Diagram:
+		buf.WriteString("<p>" + r.RenderDiagram(b) + "</p>\n")
+	case *ast.Table:
+		buf.WriteString(r.RenderTable(b))
+	case *ast.Sheet:
+		buf.WriteString(r.RenderEmbeddedTable(b.Table, b.Src))
+	case *ast.Bitable:
+		buf.WriteString(r.RenderEmbeddedTable(b.Table, b.Src))
+	case *ast.Callout:
+		buf.WriteString(r.RenderCallout(b))
+	case *ast.Grid:
+		buf.WriteString(r.RenderGrid(b))
+	}
+	return buf.String()
+}
+
+func (r *HTMLRenderer) RenderInlines(inlines []ast.Inline) string {
+	buf := new(strings.Builder)
+	for _, inline := range inlines {
+		buf.WriteString(r.RenderInline(inline))
+	}
+	return buf.String()
+}
+
+func (r *HTMLRenderer) RenderInline(i ast.Inline) string {
+	switch i := i.(type) {
+	case *ast.Text:
+		return r.RenderTextRun(i)
+	case *ast.UserMention:
+		return r.RenderUserMention(i)
+	case *ast.DocMention:
+		return fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(i.URL), html.EscapeString(i.Title))
+	case *ast.InlineMath:
+		return "<span class=\"math\">\\(" + html.EscapeString(i.Content) + "\\)</span>"
+	}
+	return ""
+}
+
+func (r *HTMLRenderer) RenderUserMention(m *ast.UserMention) string {
+	if r.conf.MentionStyle == MentionStyleID || m.Name == "" {
+		return "<span class=\"mention\">" + html.EscapeString(m.UserID) + "</span>"
+	}
+	if r.conf.MentionStyle == MentionStyleMailto && m.Email != "" {
+		return fmt.Sprintf(
+			"<a class=\"mention\" href=\"mailto:%s\">@%s</a>",
+			html.EscapeString(m.Email), html.EscapeString(m.Name),
+		)
+	}
+	return "<span class=\"mention\">@" + html.EscapeString(m.Name) + "</span>"
+}
+
+func (r *HTMLRenderer) RenderTextRun(t *ast.Text) string {
+	// unlike markdown, every style can be nested
+	content := html.EscapeString(t.Content)
+	marks := []struct {
+		mark ast.Mark
+		tag  string
+	}{
+		{ast.InlineCode, "code"},
+		{ast.Underline, "u"},
+		{ast.Strikethrough, "del"},
+		{ast.Italic, "em"},
+		{ast.Bold, "strong"},
+	}
+	for _, m := range marks {
+		if t.Marks.Has(m.mark) {
+			content = "<" + m.tag + ">" + content + "</" + m.tag + ">"
+		}
+	}
+	if t.Link != "" {
+		content = fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(t.Link), content)
+	}
+	return content
+}
+
+// iframeTag embeds the page like the document does, it is shared by the
+// markdown renderer with HTML tags enabled.
+func iframeTag(f *ast.Iframe) string {
+	return fmt.Sprintf(
+		"<iframe src=\"%s\" title=\"%s\" width=\"100%%\" height=\"480\" frameborder=\"0\" allowfullscreen></iframe>",
+		html.EscapeString(f.URL), html.EscapeString(f.Type),
+	)
+}
+
+func (r *HTMLRenderer) RenderDiagram(d *ast.Diagram) string {
+	if d.Src != "" {
+		return r.RenderImage(&ast.Image{Token: d.Token, Src: d.Src})
+	}
+	if d.URL == "" {
+		return html.EscapeString(diagramPlaceholder(d))
+	}
+	return fmt.Sprintf(
+		"<a href=\"%s\">%s</a>",
+		html.EscapeString(d.URL), html.EscapeString(diagramPlaceholder(d)),
+	)
+}
+
+func (r *HTMLRenderer) RenderFile(f *ast.File) string {
+	name := f.Name
+	if name == "" {
+		name = f.Token
+	}
+	link := fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(f.Src), html.EscapeString(name))
+	if f.Size > 0 {
+		link += " (" + utils.FormatFileSize(f.Size) + ")"
+	}
+	return link
+}
+
+func (r *HTMLRenderer) RenderImage(img *ast.Image) string {
+	if img.Width > 0 && img.Height > 0 {
+		return fmt.Sprintf(
+			"<img src=\"%s\" alt=\"%s\" width=\"%d\" height=\"%d\">",
+			html.EscapeString(img.Src), html.EscapeString(img.Caption), img.Width, img.Height,
+		)
+	}
+	return fmt.Sprintf(
+		"<img src=\"%s\" alt=\"%s\">",
+		html.EscapeString(img.Src), html.EscapeString(img.Caption),
+	)
+}
+
+// renderImageBlock puts a captioned image in a figure, aligned as in the
+// document.
+func (r *HTMLRenderer) renderImageBlock(img *ast.Image) string {
+	style := ""
+	if img.Align == "center" || img.Align == "right" {
+		style = " style=\"text-align:" + img.Align + "\""
+	}
+	if img.Caption == "" {
+		return "<p" + style + ">" + r.RenderImage(img) + "</p>\n"
+	}
+	return fmt.Sprintf(
+		"<figure%s>%s<figcaption>%s</figcaption></figure>\n",
+		style, r.RenderImage(img), html.EscapeString(img.Caption),
+	)
+}
+
+func (r *HTMLRenderer) RenderList(l *ast.List) string {
+	buf := new(strings.Builder)
+	tag := "ul"
+	if l.Ordered {
+		tag = "ol"
+	}
+	if l.Task {
+		buf.WriteString("<ul class=\"task-list\">\n")
+	} else {
+		buf.WriteString("<" + tag + ">\n")
+	}
+	for _, item := range l.Items {
+		buf.WriteString("<li>")
+		if l.Task {
+			if item.Done {
+				buf.WriteString("<input type=\"checkbox\" checked disabled> ")
+			} else {
+				buf.WriteString("<input type=\"checkbox\" disabled> ")
+			}
+		}
+		buf.WriteString(r.RenderInlines(item.Content))
+		if len(item.Children) > 0 {
+			buf.WriteString("\n" + r.RenderBlocks(item.Children))
+		}
+		buf.WriteString("</li>\n")
+	}
+	buf.WriteString("</" + tag + ">\n")
+	return buf.String()
+}
+
+func (r *HTMLRenderer) RenderCode(c *ast.Code) string {
+	code := strings.TrimSpace(plainText(c.Content))
+	lexer := lexers.Get(c.Language)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err == nil {
+		buf := new(strings.Builder)
+		if err = r.formatter.Format(buf, r.style, iterator); err == nil {
+			return buf.String() + "\n"
+		}
+	}
+	return "<pre><code>" + html.EscapeString(code) + "</code></pre>\n"
+}
+
+func (r *HTMLRenderer) RenderTable(t *ast.Table) string {
+	buf := new(strings.Builder)
+	buf.WriteString("<table>\n")
+	for i, row := range t.Rows {
+		tag := "td"
+		if i == 0 {
+			tag = "th"
+		}
+		buf.WriteString("<tr>\n")
+		for _, cell := range row {
+			if cell == nil {
+				continue
+			}
+			buf.WriteString("<" + tag)
+			if cell.RowSpan > 1 {
+				buf.WriteString(fmt.Sprintf(` rowspan="%d"`, cell.RowSpan))
+			}
+			if cell.ColSpan > 1 {
+				buf.WriteString(fmt.Sprintf(` colspan="%d"`, cell.ColSpan))
+			}
+			buf.WriteString(">" + r.renderTableCell(cell) + "</" + tag + ">\n")
+		}
+		buf.WriteString("</tr>\n")
+	}
+	buf.WriteString("</table>\n")
+	return buf.String()
+}
+
+func (r *HTMLRenderer) renderTableCell(cell *ast.TableCell) string {
+	// a cell holding a single paragraph stays on one line
+	if len(cell.Children) == 1 {
+		if p, ok := cell.Children[0].(*ast.Paragraph); ok {
+			return r.RenderInlines(p.Content)
+		}
+	}
+	return "\n" + r.RenderBlocks(cell.Children)
+}
+
+// RenderEmbeddedTable renders the values of a sheet or bitable as a table,
+// or links to them when they were saved aside.
+func (r *HTMLRenderer) RenderEmbeddedTable(t *ast.Table, src string) string {
+	if t != nil {
+		return r.RenderTable(t)
+	}
+	if src != "" {
+		return fmt.Sprintf(
+			"<p><a href=\"%s\">%s</a></p>\n",
+			html.EscapeString(src), html.EscapeString(path.Base(src)),
+		)
+	}
+	return ""
+}
+
+func (r *HTMLRenderer) RenderCallout(c *ast.Callout) string {
+	buf := new(strings.Builder)
+	buf.WriteString(fmt.Sprintf("<div class=\"callout callout-%s\"", calloutKind(c.BackgroundColor)))
+	if color, ok := calloutColor2CSS[c.BackgroundColor]; ok {
+		buf.WriteString(fmt.Sprintf(" style=\"background-color:%s\"", color))
+	}
+	buf.WriteString(">\n")
+	if c.Emoji != "" {
+		buf.WriteString("<span class=\"callout-emoji\">" + html.EscapeString(c.Emoji) + "</span>\n")
+	}
+	buf.WriteString(r.RenderBlocks(c.Children))
+	buf.WriteString("</div>\n")
+	return buf.String()
+}
+
+func (r *HTMLRenderer) RenderGrid(g *ast.Grid) string {
+	buf := new(strings.Builder)
+	buf.WriteString("<div class=\"grid\">\n")
+	for _, column := range g.Columns {
+		width := 100 / len(g.Columns)
+		if column.WidthRatio > 0 {
+			width = column.WidthRatio
+		}
+		buf.WriteString(fmt.Sprintf("<div style=\"width:%d%%\">\n", width))
+		buf.WriteString(r.RenderBlocks(column.Children))
+		buf.WriteString("</div>\n")
+	}
+	buf.WriteString("</div>\n")
+	return buf.String()
+}
@@ -0,0 +1,51 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/chyroc/lark"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTMLRenderer(t *testing.T) {
+	doc := &lark.DocxDocument{DocumentID: "doc", Title: "A < B"}
+	blocks := []*lark.DocxBlock{
+		newPageBlock("A < B", "text", "code"),
+		{
+			BlockID:   "text",
+			ParentID:  "doc",
+			BlockType: lark.DocxBlockTypeText,
+			Text: &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+				{TextRun: &lark.DocxTextElementTextRun{
+					Content:          "bold link",
+					TextElementStyle: &lark.DocxTextElementStyle{Bold: true, Italic: true, Link: &lark.DocxTextElementStyleLink{URL: "https%3A%2F%2Fexample.com"}},
+				}},
+			}},
+		},
+		{
+			BlockID:   "code",
+			ParentID:  "doc",
+			BlockType: lark.DocxBlockTypeCode,
+			Code:      newTextElements("package main"),
+		},
+	}
+	blocks[2].Code.Style = &lark.DocxTextStyle{Language: lark.DocxCodeLanguageGo}
+
+	parser := core.NewParser()
+	document := parser.ParseDocxContent(doc, blocks)
+	renderer, err := core.NewRenderer(core.FormatHTML, core.NewConfig("", "").Output)
+	assert.Nil(t, err)
+	html := renderer.Render(document)
+
+	assert.True(t, strings.HasPrefix(html, "<!DOCTYPE html>\n"))
+	assert.Contains(t, html, "<title>A &lt; B</title>")
+	assert.Contains(t, html, "<h1>A &lt; B</h1>")
+	assert.Contains(t, html, `<p><a href="https://example.com"><strong><em>bold link</em></strong></a></p>`)
+	assert.Contains(t, html, `<span class="kn">package</span>`)
+	assert.Contains(t, html, ".chroma")
+
+	_, err = core.NewRenderer("pdf", core.NewConfig("", "").Output)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+
+	"github.com/Wsine/feishu2md/ast"
+	"github.com/Wsine/feishu2md/utils"
+)
+
+// ImageNaming decides the file names of the images of a document, Title is
+// only used by the title strategy.
+type ImageNaming struct {
+	Strategy string
+	Title    string
+}
+
+// FileName returns the name of the index-th image of the document, an
+// unknown strategy falls back to the token.
+func (n ImageNaming) FileName(index int, token, ext string, data []byte) string {
+	switch n.Strategy {
+	case ImageNamingSHA256:
+		return hashImage(data) + ext
+	case ImageNamingTitle:
+		title := utils.SanitizeFileName(n.Title, "image")
+		return fmt.Sprintf("%s-%d%s", title, index+1, ext)
+	default:
+		return token + ext
+	}
+}
+
+// CanEmbedImage tells whether an image is small enough to be embedded as a
+// data URI, a zero EmbedImageMaxKB means no limit.
+func CanEmbedImage(conf OutputConfig, data []byte) bool {
+	return conf.EmbedImageMaxKB <= 0 || int64(len(data)) <= conf.EmbedImageMaxKB<<10
+}
+
+// ImageDataURI encodes an image as a data URI, the media type comes from
+// the file extension or is sniffed from the content.
+func ImageDataURI(filename string, data []byte) string {
+	mediaType := mime.TypeByExtension(filepath.Ext(filename))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+	}
+	return fmt.Sprintf("data:%s;base64,%s", mediaType, base64.StdEncoding.EncodeToString(data))
+}
+
+// DocxImageProps are the properties of an image block missing from the SDK
+type DocxImageProps struct {
+	Align   int64 `json:"align,omitempty"`
+	Caption *struct {
+		Content string `json:"content,omitempty"`
+	} `json:"caption,omitempty"`
+}
+
+var docxImageAligns = map[int64]string{
+	1: "left",
+	2: "center",
+	3: "right",
+}
+
+func applyImageProps(img *ast.Image, props *DocxImageProps) {
+	img.Align = docxImageAligns[props.Align]
+	if props.Caption != nil {
+		img.Caption = props.Caption.Content
+	}
+}
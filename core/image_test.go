@@ -0,0 +1,44 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageNamingFileName(t *testing.T) {
+	data := []byte("image")
+	tests := []struct {
+		naming core.ImageNaming
+		want   string
+	}{
+		{core.ImageNaming{}, "boxcn1.png"},
+		{core.ImageNaming{Strategy: core.ImageNamingToken}, "boxcn1.png"},
+		{
+			core.ImageNaming{Strategy: core.ImageNamingSHA256},
+			"6105d6cc76af400325e94d588ce511be5bfdbb73b437dc51eca43917d7a43e3d.png",
+		},
+		{core.ImageNaming{Strategy: core.ImageNamingTitle, Title: "A/B notes"}, "A_B notes-3.png"},
+		{core.ImageNaming{Strategy: core.ImageNamingTitle}, "image-3.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.naming.Strategy, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.naming.FileName(2, "boxcn1", ".png", data))
+		})
+	}
+}
+
+func TestImageDataURI(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+	assert.Equal(t, "data:image/png;base64,iVBORw0KGgo=", core.ImageDataURI("static/boxcn1.png", png))
+	// no extension, sniff the content
+	assert.Equal(t, "data:image/png;base64,iVBORw0KGgo=", core.ImageDataURI("static/boxcn1", png))
+
+	conf := core.NewConfig("", "").Output
+	conf.EmbedImageMaxKB = 1
+	assert.True(t, core.CanEmbedImage(conf, make([]byte, 1024)))
+	assert.False(t, core.CanEmbedImage(conf, make([]byte, 1025)))
+	conf.EmbedImageMaxKB = 0
+	assert.True(t, core.CanEmbedImage(conf, make([]byte, 1<<20)))
+}
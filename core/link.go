@@ -0,0 +1,46 @@
+package core
+
+import (
+	"regexp"
+
+	"github.com/Wsine/feishu2md/ast"
+)
+
+var documentURLPattern = regexp.MustCompile(
+	`^https://[a-zA-Z0-9-]+\.(feishu\.cn|larksuite\.com)/(docx|wiki)/([a-zA-Z0-9]+)`,
+)
+
+// ParseDocumentToken returns the token of a feishu/larksuite docx or wiki
+// page URL, anything else is not a document of an export.
+func ParseDocumentToken(url string) (string, bool) {
+	matchResult := documentURLPattern.FindStringSubmatch(url)
+	if matchResult == nil {
+		return "", false
+	}
+	return matchResult[3], true
+}
+
+// RewriteDocumentLinks points the document mentions and the hyperlinks to
+// other documents at the local files returned by resolve, which is given
+// the docx or wiki token. Links that resolve does not know are left alone.
+func RewriteDocumentLinks(doc *ast.Document, resolve func(token string) (string, bool)) {
+	ast.Walk(doc, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.DocMention:
+			if link, ok := resolve(n.Token); ok {
+				n.URL = link
+			} else if token, ok := ParseDocumentToken(n.URL); ok {
+				if link, ok := resolve(token); ok {
+					n.URL = link
+				}
+			}
+		case *ast.Text:
+			if token, ok := ParseDocumentToken(n.Link); ok {
+				if link, ok := resolve(token); ok {
+					n.Link = link
+				}
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,75 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/88250/lute"
+	"github.com/Wsine/feishu2md/ast"
+	"github.com/Wsine/feishu2md/core"
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDocumentToken(t *testing.T) {
+	tests := []struct {
+		url   string
+		token string
+		ok    bool
+	}{
+		{"https://sample.feishu.cn/docx/doxcnABC123", "doxcnABC123", true},
+		{"https://sample.larksuite.com/wiki/wikcnXYZ?from=share", "wikcnXYZ", true},
+		{"https://sample.feishu.cn/drive/folder/fldcn123", "", false},
+		{"https://example.com/docx/doxcnABC123", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			token, ok := core.ParseDocumentToken(tt.url)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.token, token)
+		})
+	}
+}
+
+func TestRewriteDocumentLinks(t *testing.T) {
+	mention := &ast.DocMention{Token: "doxcnA", Title: "A", URL: "https://sample.feishu.cn/docx/doxcnA"}
+	wikiMention := &ast.DocMention{Token: "doxcnB", Title: "B", URL: "https://sample.feishu.cn/wiki/wikcnB"}
+	unknown := &ast.DocMention{Token: "doxcnC", Title: "C", URL: "https://sample.feishu.cn/docx/doxcnC"}
+	docLink := &ast.Text{Content: "A", Link: "https://sample.feishu.cn/docx/doxcnA"}
+	external := &ast.Text{Content: "site", Link: "https://example.com/docx/doxcnA"}
+	doc := &ast.Document{Children: []ast.Block{
+		&ast.Paragraph{Content: []ast.Inline{mention, wikiMention, unknown, docLink, external}},
+	}}
+
+	links := map[string]string{"doxcnA": "a.md", "wikcnB": "wiki/b.md"}
+	core.RewriteDocumentLinks(doc, func(token string) (string, bool) {
+		link, ok := links[token]
+		return link, ok
+	})
+
+	assert.Equal(t, "a.md", mention.URL)
+	assert.Equal(t, "wiki/b.md", wikiMention.URL)
+	assert.Equal(t, "https://sample.feishu.cn/docx/doxcnC", unknown.URL)
+	assert.Equal(t, "a.md", docLink.Link)
+	assert.Equal(t, "https://example.com/docx/doxcnA", external.Link)
+}
+
+func TestRewriteDocumentLinksTitlePath(t *testing.T) {
+	mention := &ast.DocMention{Token: "doxcnA", Title: "Other", URL: "https://sample.feishu.cn/docx/doxcnA"}
+	doc := &ast.Document{Children: []ast.Block{
+		&ast.Paragraph{Content: []ast.Inline{mention}},
+	}}
+	core.RewriteDocumentLinks(doc, func(token string) (string, bool) {
+		return utils.EscapeLinkPath("../Team Space/My Doc (v2).md"), true
+	})
+
+	engine := lute.New(func(l *lute.Lute) {
+		l.RenderOptions.AutoSpace = true
+	})
+	result := engine.FormatStr("md", core.NewMarkdownRenderer(core.NewConfig("", "").Output).Render(doc))
+	assert.Equal(t, "[Other](../Team%20Space/My%20Doc%20%28v2%29.md)\n", result)
+	assert.Equal(t,
+		"<p><a href=\"../Team%20Space/My%20Doc%20%28v2%29.md\">Other</a></p>\n",
+		engine.Md2HTML(result),
+	)
+}
@@ -0,0 +1,672 @@
+package core
+
+import (
+	"fmt"
+	"html"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/88250/lute"
+	"github.com/Wsine/feishu2md/ast"
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/olekukonko/tablewriter"
+)
+
+type MarkdownRenderer struct {
+	conf OutputConfig
+	// html renders the cells of the tables written as HTML
+	html *HTMLRenderer
+}
+
+func NewMarkdownRenderer(conf OutputConfig) *MarkdownRenderer {
+	return &MarkdownRenderer{conf: conf, html: NewHTMLRenderer(conf)}
+}
+
+// =============================================================
+// Renderer utils
+// =============================================================
+
+// prefixLines prepends prefix to every line of s, empty lines get the
+// prefix with trailing spaces and tabs trimmed.
+func prefixLines(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = strings.TrimRight(prefix, " \t")
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Admonition kinds shared by all callout styles, derived from the
+// background color of the callout block.
+const (
+	calloutKindNote      = "note"
+	calloutKindTip       = "tip"
+	calloutKindImportant = "important"
+	calloutKindWarning   = "warning"
+	calloutKindCaution   = "caution"
+)
+
+// calloutColor2Kind is keyed by hue, the light and dark variants of a
+// color share the same kind.
+var calloutColor2Kind = map[string]string{
+	"red":    calloutKindCaution,
+	"orange": calloutKindWarning,
+	"yellow": calloutKindWarning,
+	"green":  calloutKindTip,
+	"blue":   calloutKindNote,
+	"purple": calloutKindImportant,
+	"grey":   calloutKindNote,
+}
+
+func calloutKind(backgroundColor string) string {
+	hue := backgroundColor[strings.Index(backgroundColor, "-")+1:]
+	if kind, ok := calloutColor2Kind[hue]; ok {
+		return kind
+	}
+	return calloutKindNote
+}
+
+// MkDocs and Docusaurus share their admonition names, they have no
+// "important" or "caution" admonitions
+var calloutKind2Admonition = map[string]string{
+	calloutKindNote:      "note",
+	calloutKindTip:       "tip",
+	calloutKindImportant: "info",
+	calloutKindWarning:   "warning",
+	calloutKindCaution:   "danger",
+}
+
+func renderMarkdownTable(data [][]string) string {
+	builder := &strings.Builder{}
+	table := tablewriter.NewWriter(builder)
+	table.SetCenterSeparator("|")
+	table.SetAutoWrapText(false)
+	table.SetAutoFormatHeaders(false)
+	table.SetAutoMergeCells(false)
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetHeader(data[0])
+	table.AppendBulk(data[1:])
+	table.Render()
+	return builder.String()
+}
+
+func renderHTMLTable(data [][]string, cells [][]*ast.TableCell) string {
+	buf := new(strings.Builder)
+	buf.WriteString("<table>\n")
+	for i, row := range data {
+		tag := "td"
+		if i == 0 {
+			tag = "th"
+		}
+		buf.WriteString("<tr>\n")
+		for j, content := range row {
+			cell := cells[i][j]
+			if cell == nil {
+				continue
+			}
+			buf.WriteString("<" + tag)
+			if cell.RowSpan > 1 {
+				buf.WriteString(fmt.Sprintf(` rowspan="%d"`, cell.RowSpan))
+			}
+			if cell.ColSpan > 1 {
+				buf.WriteString(fmt.Sprintf(` colspan="%d"`, cell.ColSpan))
+			}
+			buf.WriteString(">" + content + "</" + tag + ">\n")
+		}
+		buf.WriteString("</tr>\n")
+	}
+	buf.WriteString("</table>\n")
+	return buf.String()
+}
+
+// =============================================================
+// Render the document tree to markdown
+// =============================================================
+
+func (r *MarkdownRenderer) Render(doc *ast.Document) string {
+	buf := new(strings.Builder)
+	for _, content := range r.RenderBlocks(doc.Children, 0) {
+		buf.WriteString(content)
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// RenderBlocks renders each block on its own, except that every item of a
+// list is rendered separately so that containers can lay them out.
+func (r *MarkdownRenderer) RenderBlocks(blocks []ast.Block, indentLevel int) []string {
+	var contents []string
+	for _, b := range blocks {
+		if list, ok := b.(*ast.List); ok {
+			for i := range list.Items {
+				contents = append(contents, r.RenderListItem(list, i, indentLevel))
+			}
+			continue
+		}
+		contents = append(contents, r.RenderBlock(b, indentLevel))
+	}
+	return contents
+}
+
+// RenderBlock renders a block, every line of it indented by indentLevel
+// tabs such that a multi-line block stays inside its list item.
+func (r *MarkdownRenderer) RenderBlock(b ast.Block, indentLevel int) string {
+	content := r.renderBlock(b)
+	if indentLevel == 0 {
+		return content
+	}
+	body := strings.TrimRight(content, "\n")
+	return prefixLines(body, strings.Repeat("\t", indentLevel)) + content[len(body):]
+}
+
+func (r *MarkdownRenderer) renderBlock(b ast.Block) string {
+	buf := new(strings.Builder)
+	switch b := b.(type) {
+	case *ast.Paragraph:
+		buf.WriteString(r.RenderText(b.Content))
+	case *ast.Heading:
+		buf.WriteString(strings.Repeat("#", b.Level) + " ")
+		buf.WriteString(r.RenderText(b.Content))
+	case *ast.List:
+		for i := range b.Items {
+			buf.WriteString(r.RenderListItem(b, i, 0))
+		}
+	case *ast.Code:
+		buf.WriteString("```" + b.Language + "\n")
+		buf.WriteString(strings.TrimSpace(r.RenderText(b.Content)))
+		buf.WriteString("\n```\n")
+	case *ast.Math:
+		buf.WriteString("$$\n")
+		buf.WriteString(b.Content)
+		buf.WriteString("\n$$\n")
+	case *ast.Blockquote:
+		for _, content := range r.RenderBlocks(b.Children, 0) {
+			buf.WriteString("> ")
+			buf.WriteString(content)
+		}
+	case *ast.ThematicBreak:
+		buf.WriteString("---\n")
+	case *ast.Image:
+		buf.WriteString(r.RenderImage(b))
+	case *ast.File:
+		buf.WriteString(r.RenderFile(b))
+	case *ast.Iframe:
+		buf.WriteString(r.RenderIframe(b))
+	case *ast.Diagram:
+		buf.WriteString(r.RenderDiagram(b))
+	case *ast.Table:
+		buf.WriteString(r.RenderTable(b))
+	case *ast.Sheet:
+		buf.WriteString(r.RenderEmbeddedTable(b.Table, b.Src))
+	case *ast.Bitable:
+		buf.WriteString(r.RenderEmbeddedTable(b.Table, b.Src))
+	case *ast.Callout:
+		buf.WriteString(r.RenderCallout(b))
+	case *ast.Grid:
+		buf.WriteString(r.RenderGrid(b))
+	}
+	return buf.String()
+}
+
+func (r *MarkdownRenderer) RenderText(inlines []ast.Inline) string {
+	buf := new(strings.Builder)
+	for _, inline := range inlines {
+		buf.WriteString(r.RenderInline(inline, len(inlines) > 1))
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+func (r *MarkdownRenderer) RenderInline(i ast.Inline, inline bool) string {
+	switch i := i.(type) {
+	case *ast.Text:
+		return r.RenderTextRun(i)
+	case *ast.UserMention:
+		return r.RenderUserMention(i)
+	case *ast.DocMention:
+		return fmt.Sprintf("[%s](%s)", i.Title, i.URL)
+	case *ast.InlineMath:
+		symbol := "$$"
+		if inline {
+			symbol = "$"
+		}
+		return symbol + i.Content + symbol
+	}
+	return ""
+}
+
+// RenderUserMention writes the mention as @name, see PrettifyMarkdown for
+// keeping it together.
+func (r *MarkdownRenderer) RenderUserMention(m *ast.UserMention) string {
+	if r.conf.MentionStyle == MentionStyleID || m.Name == "" {
+		return m.UserID
+	}
+	if r.conf.MentionStyle == MentionStyleMailto && m.Email != "" {
+		return fmt.Sprintf("[@%s](mailto:%s)", m.Name, m.Email)
+	}
+	return "@" + m.Name
+}
+
+func (r *MarkdownRenderer) RenderTextRun(t *ast.Text) string {
+	// only the first matching style is applied
+	buf := new(strings.Builder)
+	postWrite := ""
+	useHTMLTags := r.conf.UseHTMLTags
+	if t.Marks.Has(ast.Bold) {
+		if useHTMLTags {
+			buf.WriteString("<strong>")
+			postWrite = "</strong>"
+		} else {
+			buf.WriteString("**")
+			postWrite = "**"
+		}
+	} else if t.Marks.Has(ast.Italic) {
+		if useHTMLTags {
+			buf.WriteString("<em>")
+			postWrite = "</em>"
+		} else {
+			buf.WriteString("_")
+			postWrite = "_"
+		}
+	} else if t.Marks.Has(ast.Strikethrough) {
+		if useHTMLTags {
+			buf.WriteString("<del>")
+			postWrite = "</del>"
+		} else {
+			buf.WriteString("~~")
+			postWrite = "~~"
+		}
+	} else if t.Marks.Has(ast.Underline) {
+		buf.WriteString("<u>")
+		postWrite = "</u>"
+	} else if t.Marks.Has(ast.InlineCode) {
+		buf.WriteString("`")
+		postWrite = "`"
+	} else if t.Link != "" {
+		buf.WriteString("[")
+		postWrite = fmt.Sprintf("](%s)", t.Link)
+	}
+	buf.WriteString(t.Content)
+	buf.WriteString(postWrite)
+	return buf.String()
+}
+
+var imageAltEscaper = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "\n", " ")
+
+// RenderImage uses the caption as alt text. The alignment needs HTML, it is
+// only kept with the html image style.
+func (r *MarkdownRenderer) RenderImage(img *ast.Image) string {
+	hasSize := img.Width > 0 && img.Height > 0
+	aligned := img.Align == "center" || img.Align == "right"
+	switch {
+	case r.conf.ImageStyle == ImageStyleHTML && (hasSize || aligned):
+		tag := "<img src=\"" + html.EscapeString(img.Src) + "\""
+		if img.Caption != "" {
+			tag += " alt=\"" + html.EscapeString(img.Caption) + "\""
+		}
+		if hasSize {
+			tag += fmt.Sprintf(" width=\"%d\" height=\"%d\"", img.Width, img.Height)
+		}
+		tag += ">"
+		if aligned {
+			tag = fmt.Sprintf("<p align=\"%s\">%s</p>", img.Align, tag)
+		}
+		return tag + "\n"
+	case r.conf.ImageStyle == ImageStylePandoc && hasSize:
+		return fmt.Sprintf(
+			"![%s](%s){width=%dpx height=%dpx}\n",
+			imageAltEscaper.Replace(img.Caption), img.Src, img.Width, img.Height,
+		)
+	default:
+		return fmt.Sprintf("![%s](%s)\n", imageAltEscaper.Replace(img.Caption), img.Src)
+	}
+}
+
+func (r *MarkdownRenderer) RenderFile(f *ast.File) string {
+	name := f.Name
+	if name == "" {
+		name = f.Token
+	}
+	if f.Size > 0 {
+		return fmt.Sprintf("[%s](%s) (%s)\n", name, f.Src, utils.FormatFileSize(f.Size))
+	}
+	return fmt.Sprintf("[%s](%s)\n", name, f.Src)
+}
+
+// RenderIframe links to the embedded page, or embeds it as in the document
+// with HTML tags enabled.
+func (r *MarkdownRenderer) RenderIframe(f *ast.Iframe) string {
+	if r.conf.UseHTMLTags {
+		return iframeTag(f) + "\n"
+	}
+	return fmt.Sprintf("[%s](%s)\n", f.Type, f.URL)
+}
+
+// RenderDiagram renders the exported image of a diagram, or a placeholder
+// linking back to it.
+func (r *MarkdownRenderer) RenderDiagram(d *ast.Diagram) string {
+	if d.Src != "" {
+		return r.RenderImage(&ast.Image{Token: d.Token, Src: d.Src})
+	}
+	if d.URL == "" {
+		return diagramPlaceholder(d) + "\n"
+	}
+	return fmt.Sprintf("[%s](%s)\n", diagramPlaceholder(d), d.URL)
+}
+
+func (r *MarkdownRenderer) RenderListItem(list *ast.List, index int, indentLevel int) string {
+	buf := new(strings.Builder)
+	buf.WriteString(strings.Repeat("\t", indentLevel))
+
+	item := list.Items[index]
+	switch {
+	case list.Ordered:
+		buf.WriteString(fmt.Sprintf("%d. ", index+1))
+	case list.Task && item.Done:
+		buf.WriteString("- [x] ")
+	case list.Task:
+		buf.WriteString("- [ ] ")
+	default:
+		buf.WriteString("- ")
+	}
+	buf.WriteString(r.RenderText(item.Content))
+
+	for _, content := range r.RenderBlocks(item.Children, indentLevel+1) {
+		buf.WriteString(content)
+	}
+
+	return buf.String()
+}
+
+// cellFitsGFM reports whether the content of a cell fits in a GFM pipe
+// table. Only paragraphs, lists, images and files fit on the single line of
+// a cell, the other blocks need an HTML table.
+func cellFitsGFM(cell *ast.TableCell) bool {
+	return blocksFitGFM(cell.Children)
+}
+
+func blocksFitGFM(blocks []ast.Block) bool {
+	for _, b := range blocks {
+		switch b := b.(type) {
+		case *ast.Paragraph, *ast.Image, *ast.File:
+		case *ast.List:
+			for _, item := range b.Items {
+				if !blocksFitGFM(item.Children) {
+					return false
+				}
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// RenderTableCell renders the children of a table cell on a single line,
+// paragraphs separated by <br> and lists as inline HTML. The content of a
+// cell of an HTML table is HTML too, GFM does not parse markdown inside it.
+func (r *MarkdownRenderer) RenderTableCell(cell *ast.TableCell, useHTML bool) string {
+	buf := new(strings.Builder)
+	// paragraphs need a <br> in between, HTML block elements do not
+	needBreak := false
+	for _, child := range cell.Children {
+		switch child := child.(type) {
+		case *ast.List:
+			buf.WriteString(r.renderTableCellList(child, useHTML))
+			needBreak = false
+		case *ast.Code:
+			code := html.EscapeString(strings.TrimSpace(plainText(child.Content)))
+			buf.WriteString("<pre><code>" + strings.ReplaceAll(code, "\n", "<br>") + "</code></pre>")
+			needBreak = false
+		case *ast.Paragraph, *ast.Image, *ast.File:
+			if needBreak {
+				buf.WriteString("<br>")
+			}
+			buf.WriteString(r.renderTableCellBlock(child, useHTML))
+			needBreak = true
+		default:
+			if needBreak && !useHTML {
+				buf.WriteString("<br>")
+			}
+			buf.WriteString(r.renderTableCellBlock(child, useHTML))
+			needBreak = !useHTML
+		}
+	}
+	return buf.String()
+}
+
+// renderTableCellBlock renders a block of a table cell on a single line,
+// paragraphs, images and files inline.
+func (r *MarkdownRenderer) renderTableCellBlock(b ast.Block, useHTML bool) string {
+	if !useHTML {
+		return strings.ReplaceAll(strings.TrimSpace(r.RenderBlock(b, 0)), "\n", "<br>")
+	}
+	switch v := b.(type) {
+	case *ast.Paragraph:
+		return strings.ReplaceAll(strings.TrimSpace(r.html.RenderInlines(v.Content)), "\n", "<br>")
+	case *ast.Image:
+		return r.html.RenderImage(v)
+	case *ast.File:
+		return r.html.RenderFile(v)
+	case *ast.Math:
+		b = &ast.Math{Content: strings.ReplaceAll(v.Content, "\n", " ")}
+	}
+	// the other blocks only have line breaks between their tags
+	return strings.ReplaceAll(strings.TrimSpace(r.html.RenderBlock(b)), "\n", "")
+}
+
+func (r *MarkdownRenderer) renderTableCellList(list *ast.List, useHTML bool) string {
+	tag := "ul"
+	if list.Ordered {
+		tag = "ol"
+	}
+
+	buf := new(strings.Builder)
+	buf.WriteString("<" + tag + ">")
+	for _, item := range list.Items {
+		buf.WriteString("<li>")
+		buf.WriteString(r.renderTableCellBlock(&ast.Paragraph{Content: item.Content}, useHTML))
+		for _, child := range item.Children {
+			if nested, ok := child.(*ast.List); ok {
+				buf.WriteString(r.renderTableCellList(nested, useHTML))
+			} else {
+				buf.WriteString("<br>" + r.renderTableCellBlock(child, useHTML))
+			}
+		}
+		buf.WriteString("</li>")
+	}
+	buf.WriteString("</" + tag + ">")
+	return buf.String()
+}
+
+func (r *MarkdownRenderer) RenderTable(t *ast.Table) string {
+	// - First row as header
+	// - Merged cells or block content that GFM cannot hold are rendered as
+	//   HTML unless configured otherwise
+	merged := false
+	fitsGFM := true
+	for _, row := range t.Rows {
+		for _, cell := range row {
+			if cell == nil {
+				merged = true
+			} else {
+				fitsGFM = fitsGFM && cellFitsGFM(cell)
+			}
+		}
+	}
+
+	useHTML := merged || !fitsGFM
+	switch r.conf.TableStyle {
+	case TableStyleMarkdown:
+		useHTML = false
+	case TableStyleHTML:
+		useHTML = true
+	}
+
+	rows := make([][]string, len(t.Rows))
+	for i, row := range t.Rows {
+		rows[i] = make([]string, len(row))
+		for j, cell := range row {
+			if cell != nil {
+				rows[i][j] = r.RenderTableCell(cell, useHTML)
+			}
+		}
+	}
+
+	buf := new(strings.Builder)
+	if useHTML {
+		buf.WriteString(renderHTMLTable(rows, t.Rows))
+	} else {
+		for i, row := range rows {
+			for j := range row {
+				rows[i][j] = strings.ReplaceAll(rows[i][j], "|", "\\|")
+			}
+		}
+		buf.WriteString(renderMarkdownTable(rows))
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+// RenderEmbeddedTable renders the values of a sheet or bitable as a table,
+// or links to them when they were saved aside. One not fetched is left out.
+func (r *MarkdownRenderer) RenderEmbeddedTable(t *ast.Table, src string) string {
+	if t != nil {
+		return r.RenderTable(t)
+	}
+	if src != "" {
+		return fmt.Sprintf("[%s](%s)\n", path.Base(src), src)
+	}
+	return ""
+}
+
+func (r *MarkdownRenderer) RenderCallout(c *ast.Callout) string {
+	content := new(strings.Builder)
+	for _, child := range r.RenderBlocks(c.Children, 0) {
+		content.WriteString(child)
+		content.WriteString("\n")
+	}
+	body := strings.TrimRight(content.String(), "\n")
+	emoji := c.Emoji
+	kind := calloutKind(c.BackgroundColor)
+
+	buf := new(strings.Builder)
+	switch r.conf.CalloutStyle {
+	case CalloutStyleGitHub:
+		buf.WriteString(fmt.Sprintf("> [!%s]\n", strings.ToUpper(kind)))
+		if emoji != "" {
+			body = emoji + " " + body
+		}
+		buf.WriteString(prefixLines(body, "> "))
+	case CalloutStyleMkDocs:
+		buf.WriteString("!!! " + calloutKind2Admonition[kind])
+		if emoji != "" {
+			buf.WriteString(fmt.Sprintf(" \"%s\"", emoji))
+		}
+		buf.WriteString("\n")
+		buf.WriteString(prefixLines(body, "    "))
+	case CalloutStyleDocusaurus:
+		buf.WriteString(":::" + calloutKind2Admonition[kind])
+		if emoji != "" {
+			buf.WriteString(" " + emoji)
+		}
+		buf.WriteString("\n\n")
+		buf.WriteString(body)
+		buf.WriteString("\n\n:::")
+	default:
+		if emoji != "" {
+			body = emoji + " " + body
+		}
+		buf.WriteString(prefixLines(body, "> "))
+	}
+	buf.WriteString("\n")
+
+	return buf.String()
+}
+
+func (r *MarkdownRenderer) RenderGrid(g *ast.Grid) string {
+	buf := new(strings.Builder)
+
+	useHTML := r.conf.GridStyle == GridStyleHTML
+	if useHTML {
+		buf.WriteString("<div style=\"display:flex\">\n")
+	}
+	for _, column := range g.Columns {
+		content := r.RenderGridColumn(column)
+		if useHTML {
+			width := 100 / len(g.Columns)
+			if column.WidthRatio > 0 {
+				width = column.WidthRatio
+			}
+			buf.WriteString(fmt.Sprintf("<div style=\"width:%d%%\">\n\n", width))
+			buf.WriteString(strings.TrimRight(content, "\n"))
+			buf.WriteString("\n\n</div>\n")
+		} else {
+			buf.WriteString(content)
+		}
+	}
+	if useHTML {
+		buf.WriteString("</div>\n")
+	}
+
+	return strings.TrimRight(buf.String(), "\n") + "\n"
+}
+
+func (r *MarkdownRenderer) RenderGridColumn(c *ast.GridColumn) string {
+	buf := new(strings.Builder)
+
+	for _, content := range r.RenderBlocks(c.Children, 0) {
+		buf.WriteString(content)
+		buf.WriteString("\n")
+	}
+
+	return buf.String()
+}
+
+// =============================================================
+// Format the rendered markdown
+// =============================================================
+
+// mkDocsAdmonition matches an admonition at the top level with its indented
+// body, including the blank lines in between.
+var mkDocsAdmonition = regexp.MustCompile(`(?m)^!!! .*\n(?:(?: {4}.*)?\n)*`)
+
+// cjkMention is an @ followed by a CJK character, which lute would space
+// apart as it does for every CJK next to an ASCII character.
+var cjkMention = regexp.MustCompile(`@([^\x00-\x7f])`)
+
+// mentionPlaceholder stands for the @ of a CJK mention while lute formats
+// the markdown, lute adds no space around a private use character.
+const mentionPlaceholder = "\ue000"
+
+// PrettifyMarkdown formats the rendered markdown with lute. Lute reflows the
+// indented body of MkDocs admonitions and spaces the @ of a mention apart
+// from a CJK name, so they are swapped for placeholders and restored as
+// rendered afterwards.
+func PrettifyMarkdown(md string, conf OutputConfig) string {
+	var admonitions []string
+	if conf.CalloutStyle == CalloutStyleMkDocs {
+		md = mkDocsAdmonition.ReplaceAllStringFunc(md, func(m string) string {
+			block := strings.TrimRight(m, "\n")
+			admonitions = append(admonitions, block)
+			return fmt.Sprintf("FEISHU2MDADMONITION%d", len(admonitions)-1) + m[len(block):]
+		})
+	}
+	md = cjkMention.ReplaceAllString(md, mentionPlaceholder+"$1")
+
+	engine := lute.New(func(l *lute.Lute) {
+		l.RenderOptions.AutoSpace = true
+	})
+	md = engine.FormatStr("md", md)
+
+	md = strings.ReplaceAll(md, mentionPlaceholder, "@")
+	for i := len(admonitions) - 1; i >= 0; i-- {
+		md = strings.Replace(md, fmt.Sprintf("FEISHU2MDADMONITION%d", i), admonitions[i], 1)
+	}
+	return md
+}
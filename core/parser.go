@@ -1,26 +1,28 @@
 package core
 
 import (
-	"context"
-	"fmt"
 	"strings"
 
+	"github.com/Wsine/feishu2md/ast"
 	"github.com/Wsine/feishu2md/utils"
 	"github.com/chyroc/lark"
-	"github.com/olekukonko/tablewriter"
 )
 
+// Parser turns the blocks of a docx into a document. BlockProps holds the
+// properties of the blocks missing from the SDK types, as returned by
+// Client.GetDocxContent, it may be left empty.
 type Parser struct {
-	ctx       context.Context
-	ImgTokens []string
-	blockMap  map[string]*lark.DocxBlock
+	ImgTokens  []string
+	FileTokens []string
+	BlockProps map[string]*DocxBlockProps
+	blockMap   map[string]*lark.DocxBlock
 }
 
-func NewParser(ctx context.Context) *Parser {
+func NewParser() *Parser {
 	return &Parser{
-		ctx:       ctx,
-		ImgTokens: make([]string, 0),
-		blockMap:  make(map[string]*lark.DocxBlock),
+		ImgTokens:  make([]string, 0),
+		FileTokens: make([]string, 0),
+		blockMap:   make(map[string]*lark.DocxBlock),
 	}
 }
 
@@ -98,301 +100,395 @@ var DocxCodeLang2MdStr = map[lark.DocxCodeLanguage]string{
 	lark.DocxCodeLanguageYAML:         "yaml",
 }
 
-func renderMarkdownTable(data [][]string) string {
-	builder := &strings.Builder{}
-	table := tablewriter.NewWriter(builder)
-	table.SetCenterSeparator("|")
-	table.SetAutoWrapText(false)
-	table.SetAutoFormatHeaders(false)
-	table.SetAutoMergeCells(false)
-	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
-	table.SetHeader(data[0])
-	table.AppendBulk(data[1:])
-	table.Render()
-	return builder.String()
+var DocxCalloutEmoji2Str = map[string]string{
+	"bulb":               "💡",
+	"pushpin":            "📌",
+	"round_pushpin":      "📍",
+	"memo":               "📝",
+	"books":              "📚",
+	"warning":            "⚠️",
+	"no_entry":           "⛔",
+	"x":                  "❌",
+	"exclamation":        "❗",
+	"question":           "❓",
+	"white_check_mark":   "✅",
+	"heavy_check_mark":   "✔️",
+	"information_source": "ℹ️",
+	"star":               "⭐",
+	"fire":               "🔥",
+	"rocket":             "🚀",
+	"tada":               "🎉",
+	"gift":               "🎁",
+	"bell":               "🔔",
+	"mag":                "🔍",
+	"key":                "🔑",
+	"lock":               "🔒",
+	"link":               "🔗",
+	"calendar":           "📆",
+	"clock3":             "🕒",
+	"thumbsup":           "👍",
+	"eyes":               "👀",
+	"construction":       "🚧",
+	"rotating_light":     "🚨",
+	"speech_balloon":     "💬",
+}
+
+var DocxCalloutColor2Str = map[lark.DocxCalloutBackgroundColor]string{
+	lark.DocxCalloutBackgroundColorLightRed:    "light-red",
+	lark.DocxCalloutBackgroundColorLightOrange: "light-orange",
+	lark.DocxCalloutBackgroundColorLightYellow: "light-yellow",
+	lark.DocxCalloutBackgroundColorLightGreen:  "light-green",
+	lark.DocxCalloutBackgroundColorLightBlue:   "light-blue",
+	lark.DocxCalloutBackgroundColorLightPurple: "light-purple",
+	lark.DocxCalloutBackgroundColorLightGrey:   "light-grey",
+	lark.DocxCalloutBackgroundColorDarkRed:     "dark-red",
+	lark.DocxCalloutBackgroundColorDarkOrange:  "dark-orange",
+	lark.DocxCalloutBackgroundColorDarkYellow:  "dark-yellow",
+	lark.DocxCalloutBackgroundColorDarkGreen:   "dark-green",
+	lark.DocxCalloutBackgroundColorDarkBlue:    "dark-blue",
+	lark.DocxCalloutBackgroundColorDarkPurple:  "dark-purple",
+	lark.DocxCalloutBackgroundColorDarkGrey:    "dark-grey",
+}
+
+var DocxIframeType2Str = map[lark.DocxIframeComponentType]string{
+	lark.DocxIframeComponentTypeBilibili:      "Bilibili",
+	lark.DocxIframeComponentTypeXigua:         "Xigua Video",
+	lark.DocxIframeComponentTypeYouku:         "Youku",
+	lark.DocxIframeComponentTypeAirtable:      "Airtable",
+	lark.DocxIframeComponentTypeBaiduMap:      "Baidu Map",
+	lark.DocxIframeComponentTypeGaodeMap:      "Amap",
+	lark.DocxIframeComponentTypeTikTok:        "TikTok",
+	lark.DocxIframeComponentTypeFigma:         "Figma",
+	lark.DocxIframeComponentTypeModao:         "Modao",
+	lark.DocxIframeComponentTypeCanva:         "Canva",
+	lark.DocxIframeComponentTypeCodePen:       "CodePen",
+	lark.DocxIframeComponentTypeFeishuWenjuan: "Feishu Survey",
+	lark.DocxIframeComponentTypeJinshuju:      "Jinshuju",
+	lark.DocxIframeComponentTypeGoogleMap:     "Google Maps",
+	lark.DocxIframeComponentTypeYoutube:       "YouTube",
 }
 
 // =============================================================
 // Parse the new version of document (docx)
 // =============================================================
 
-func (p *Parser) ParseDocxContent(doc *lark.DocxDocument, blocks []*lark.DocxBlock) string {
+func (p *Parser) ParseDocxContent(doc *lark.DocxDocument, blocks []*lark.DocxBlock) *ast.Document {
 	for _, block := range blocks {
 		p.blockMap[block.BlockID] = block
 	}
 
 	entryBlock := p.blockMap[doc.DocumentID]
-	return p.ParseDocxBlock(entryBlock, 0)
+	return &ast.Document{
+		ID:         doc.DocumentID,
+		RevisionID: doc.RevisionID,
+		Title:      doc.Title,
+		Children:   p.ParseDocxBlockPage(entryBlock),
+	}
 }
 
-func (p *Parser) ParseDocxBlock(b *lark.DocxBlock, indentLevel int) string {
-	buf := new(strings.Builder)
-	buf.WriteString(strings.Repeat("\t", indentLevel))
+// ParseDocxBlocks parses the blocks of the given ids, consecutive list
+// items of the same kind are grouped into a single list.
+func (p *Parser) ParseDocxBlocks(blockIds []string) []ast.Block {
+	var nodes []ast.Block
+	for _, blockId := range blockIds {
+		node := p.ParseDocxBlock(p.blockMap[blockId])
+		if node == nil {
+			continue
+		}
+		if list, ok := node.(*ast.List); ok && len(nodes) > 0 {
+			if prev, ok := nodes[len(nodes)-1].(*ast.List); ok &&
+				prev.Ordered == list.Ordered && prev.Task == list.Task {
+				prev.Items = append(prev.Items, list.Items...)
+				continue
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+func (p *Parser) ParseDocxBlock(b *lark.DocxBlock) ast.Block {
+	if b == nil {
+		return nil
+	}
 	switch b.BlockType {
-	case lark.DocxBlockTypePage:
-		buf.WriteString(p.ParseDocxBlockPage(b))
 	case lark.DocxBlockTypeText:
-		buf.WriteString(p.ParseDocxBlockText(b.Text))
+		return &ast.Paragraph{Content: p.ParseDocxBlockText(b.Text)}
 	case lark.DocxBlockTypeHeading1:
-		buf.WriteString("# ")
-		buf.WriteString(p.ParseDocxBlockText(b.Heading1))
+		return &ast.Heading{Level: 1, Content: p.ParseDocxBlockText(b.Heading1)}
 	case lark.DocxBlockTypeHeading2:
-		buf.WriteString("## ")
-		buf.WriteString(p.ParseDocxBlockText(b.Heading2))
+		return &ast.Heading{Level: 2, Content: p.ParseDocxBlockText(b.Heading2)}
 	case lark.DocxBlockTypeHeading3:
-		buf.WriteString("### ")
-		buf.WriteString(p.ParseDocxBlockText(b.Heading3))
+		return &ast.Heading{Level: 3, Content: p.ParseDocxBlockText(b.Heading3)}
 	case lark.DocxBlockTypeHeading4:
-		buf.WriteString("#### ")
-		buf.WriteString(p.ParseDocxBlockText(b.Heading4))
+		return &ast.Heading{Level: 4, Content: p.ParseDocxBlockText(b.Heading4)}
 	case lark.DocxBlockTypeHeading5:
-		buf.WriteString("##### ")
-		buf.WriteString(p.ParseDocxBlockText(b.Heading5))
+		return &ast.Heading{Level: 5, Content: p.ParseDocxBlockText(b.Heading5)}
 	case lark.DocxBlockTypeHeading6:
-		buf.WriteString("###### ")
-		buf.WriteString(p.ParseDocxBlockText(b.Heading6))
+		return &ast.Heading{Level: 6, Content: p.ParseDocxBlockText(b.Heading6)}
 	case lark.DocxBlockTypeHeading7:
-		buf.WriteString("####### ")
-		buf.WriteString(p.ParseDocxBlockText(b.Heading7))
+		return &ast.Heading{Level: 7, Content: p.ParseDocxBlockText(b.Heading7)}
 	case lark.DocxBlockTypeHeading8:
-		buf.WriteString("######## ")
-		buf.WriteString(p.ParseDocxBlockText(b.Heading8))
+		return &ast.Heading{Level: 8, Content: p.ParseDocxBlockText(b.Heading8)}
 	case lark.DocxBlockTypeHeading9:
-		buf.WriteString("######### ")
-		buf.WriteString(p.ParseDocxBlockText(b.Heading9))
+		return &ast.Heading{Level: 9, Content: p.ParseDocxBlockText(b.Heading9)}
 	case lark.DocxBlockTypeBullet:
-		buf.WriteString(p.ParseDocxBlockBullet(b, indentLevel))
+		return &ast.List{Items: []*ast.ListItem{p.ParseDocxBlockListItem(b, b.Bullet)}}
 	case lark.DocxBlockTypeOrdered:
-		buf.WriteString(p.ParseDocxBlockOrdered(b, indentLevel))
+		return &ast.List{Ordered: true, Items: []*ast.ListItem{p.ParseDocxBlockListItem(b, b.Ordered)}}
+	case lark.DocxBlockTypeTodo:
+		item := p.ParseDocxBlockListItem(b, b.Todo)
+		item.Done = b.Todo.Style != nil && b.Todo.Style.Done
+		return &ast.List{Task: true, Items: []*ast.ListItem{item}}
 	case lark.DocxBlockTypeCode:
-		buf.WriteString("```" + DocxCodeLang2MdStr[b.Code.Style.Language] + "\n")
-		buf.WriteString(strings.TrimSpace(p.ParseDocxBlockText(b.Code)))
-		buf.WriteString("\n```\n")
+		language := ""
+		if b.Code.Style != nil {
+			language = DocxCodeLang2MdStr[b.Code.Style.Language]
+		}
+		return &ast.Code{Language: language, Content: p.ParseDocxBlockText(b.Code)}
 	case lark.DocxBlockTypeQuote:
-		buf.WriteString("> ")
-		buf.WriteString(p.ParseDocxBlockText(b.Quote))
+		return &ast.Blockquote{Children: []ast.Block{&ast.Paragraph{Content: p.ParseDocxBlockText(b.Quote)}}}
 	case lark.DocxBlockTypeEquation:
-		buf.WriteString("$$\n")
-		buf.WriteString(p.ParseDocxBlockText(b.Equation))
-		buf.WriteString("\n$$\n")
-	case lark.DocxBlockTypeTodo:
-		if b.Todo.Style.Done {
-			buf.WriteString("- [x] ")
-		} else {
-			buf.WriteString("- [ ] ")
-		}
-		buf.WriteString(p.ParseDocxBlockText(b.Todo))
+		return &ast.Math{Content: p.ParseDocxBlockPlainText(b.Equation)}
 	case lark.DocxBlockTypeDivider:
-		buf.WriteString("---\n")
+		return &ast.ThematicBreak{}
 	case lark.DocxBlockTypeImage:
-		buf.WriteString(p.ParseDocxBlockImage(b.Image))
-	case lark.DocxBlockTypeTableCell:
-		buf.WriteString(p.ParseDocxBlockTableCell(b))
+		img := p.ParseDocxBlockImage(b.Image)
+		if props := p.BlockProps[b.BlockID]; props != nil && props.Image != nil {
+			applyImageProps(img, props.Image)
+		}
+		return img
+	case lark.DocxBlockTypeFile:
+		return p.ParseDocxBlockFile(b.File)
+	case lark.DocxBlockTypeView:
+		// a view only wraps the file it previews
+		if len(b.Children) == 1 {
+			return p.ParseDocxBlock(p.blockMap[b.Children[0]])
+		}
+		return nil
 	case lark.DocxBlockTypeTable:
-		buf.WriteString(p.ParseDocxBlockTable(b.Table))
+		return p.ParseDocxBlockTable(b.Table)
 	case lark.DocxBlockTypeQuoteContainer:
-		buf.WriteString(p.ParseDocxBlockQuoteContainer(b))
+		return &ast.Blockquote{Children: p.ParseDocxBlocks(b.Children)}
+	case lark.DocxBlockTypeCallout:
+		return p.ParseDocxBlockCallout(b)
+	case lark.DocxBlockTypeGrid:
+		return p.ParseDocxBlockGrid(b)
+	case lark.DocxBlockTypeIframe:
+		return p.ParseDocxBlockIframe(b.Iframe)
+	case lark.DocxBlockTypeDiagram:
+		return &ast.Diagram{Kind: ast.DiagramKindDiagram, BlockID: b.BlockID}
+	case lark.DocxBlockTypeMindnote:
+		return &ast.Diagram{Kind: ast.DiagramKindMindnote, BlockID: b.BlockID, Token: b.Mindnote.Token}
+	case docxBlockTypeBoard:
+		// the token is not decoded by the SDK, see DocxBlockProps
+		d := &ast.Diagram{Kind: ast.DiagramKindWhiteboard, BlockID: b.BlockID}
+		if props := p.BlockProps[b.BlockID]; props != nil && props.Board != nil {
+			d.Token = props.Board.Token
+		}
+		return d
+	case lark.DocxBlockTypeSheet:
+		// the values are fetched separately, see Client.GetSheetValues
+		return &ast.Sheet{Token: b.Sheet.Token}
+	case lark.DocxBlockTypeBitable:
+		// the records are fetched separately, see Client.GetBitableValues
+		return &ast.Bitable{Token: b.Bitable.Token}
 	default:
+		return nil
 	}
-	return buf.String()
 }
 
-func (p *Parser) ParseDocxBlockPage(b *lark.DocxBlock) string {
-	buf := new(strings.Builder)
-
-	buf.WriteString("# ")
-	buf.WriteString(p.ParseDocxBlockText(b.Page))
-	buf.WriteString("\n")
+func (p *Parser) ParseDocxBlockPage(b *lark.DocxBlock) []ast.Block {
+	title := &ast.Heading{Level: 1, Content: p.ParseDocxBlockText(b.Page)}
+	return append([]ast.Block{title}, p.ParseDocxBlocks(b.Children)...)
+}
 
-	for _, childId := range b.Children {
-		childBlock := p.blockMap[childId]
-		buf.WriteString(p.ParseDocxBlock(childBlock, 0))
-		buf.WriteString("\n")
+func (p *Parser) ParseDocxBlockText(b *lark.DocxBlockText) []ast.Inline {
+	var inlines []ast.Inline
+	for _, e := range b.Elements {
+		if inline := p.ParseDocxTextElement(e); inline != nil {
+			inlines = append(inlines, inline)
+		}
 	}
-
-	return buf.String()
+	return inlines
 }
 
-func (p *Parser) ParseDocxBlockText(b *lark.DocxBlockText) string {
+// ParseDocxBlockPlainText concatenates the text of the elements and drops
+// their styles, as needed by equations.
+func (p *Parser) ParseDocxBlockPlainText(b *lark.DocxBlockText) string {
 	buf := new(strings.Builder)
-	numElem := len(b.Elements)
 	for _, e := range b.Elements {
-		inline := numElem > 1
-		buf.WriteString(p.ParseDocxTextElement(e, inline))
+		if e.TextRun != nil {
+			buf.WriteString(e.TextRun.Content)
+		}
+		if e.Equation != nil {
+			buf.WriteString(e.Equation.Content)
+		}
 	}
-	buf.WriteString("\n")
 	return buf.String()
 }
 
-func (p *Parser) ParseDocxTextElement(e *lark.DocxTextElement, inline bool) string {
-	buf := new(strings.Builder)
+func (p *Parser) ParseDocxTextElement(e *lark.DocxTextElement) ast.Inline {
 	if e.TextRun != nil {
-		buf.WriteString(p.ParseDocxTextElementTextRun(e.TextRun))
+		return p.ParseDocxTextElementTextRun(e.TextRun)
 	}
 	if e.MentionUser != nil {
-		buf.WriteString(e.MentionUser.UserID)
+		return &ast.UserMention{UserID: e.MentionUser.UserID}
 	}
 	if e.MentionDoc != nil {
-		buf.WriteString(
-			fmt.Sprintf("[%s](%s)", e.MentionDoc.Title, utils.UnescapeURL(e.MentionDoc.URL)))
+		return &ast.DocMention{
+			Token: e.MentionDoc.Token,
+			Title: e.MentionDoc.Title,
+			URL:   utils.UnescapeURL(e.MentionDoc.URL),
+		}
 	}
 	if e.Equation != nil {
-		symbol := "$$"
-		if inline {
-			symbol = "$"
-		}
-		buf.WriteString(symbol + strings.TrimSuffix(e.Equation.Content, "\n") + symbol)
+		return &ast.InlineMath{Content: strings.TrimSuffix(e.Equation.Content, "\n")}
 	}
-	return buf.String()
+	return nil
 }
 
-func (p *Parser) ParseDocxTextElementTextRun(tr *lark.DocxTextElementTextRun) string {
-	buf := new(strings.Builder)
-	postWrite := ""
+func (p *Parser) ParseDocxTextElementTextRun(tr *lark.DocxTextElementTextRun) *ast.Text {
+	text := &ast.Text{Content: tr.Content}
 	if style := tr.TextElementStyle; style != nil {
-		useHTMLTags := NewConfig("", "").Output.UseHTMLTags
-		if p.ctx.Value("output") != nil {
-			useHTMLTags = p.ctx.Value("output").(OutputConfig).UseHTMLTags
-		}
 		if style.Bold {
-			if useHTMLTags {
-				buf.WriteString("<strong>")
-				postWrite = "</strong>"
-			} else {
-				buf.WriteString("**")
-				postWrite = "**"
-			}
-		} else if style.Italic {
-			if useHTMLTags {
-				buf.WriteString("<em>")
-				postWrite = "</em>"
-			} else {
-				buf.WriteString("_")
-				postWrite = "_"
-			}
-		} else if style.Strikethrough {
-			if useHTMLTags {
-				buf.WriteString("<del>")
-				postWrite = "</del>"
-			} else {
-				buf.WriteString("~~")
-				postWrite = "~~"
-			}
-		} else if style.Underline {
-			buf.WriteString("<u>")
-			postWrite = "</u>"
-		} else if style.InlineCode {
-			buf.WriteString("`")
-			postWrite = "`"
-		} else if link := style.Link; link != nil {
-			buf.WriteString("[")
-			postWrite = fmt.Sprintf("](%s)", utils.UnescapeURL(link.URL))
+			text.Marks |= ast.Bold
+		}
+		if style.Italic {
+			text.Marks |= ast.Italic
+		}
+		if style.Strikethrough {
+			text.Marks |= ast.Strikethrough
+		}
+		if style.Underline {
+			text.Marks |= ast.Underline
+		}
+		if style.InlineCode {
+			text.Marks |= ast.InlineCode
+		}
+		if link := style.Link; link != nil {
+			text.Link = utils.UnescapeURL(link.URL)
 		}
 	}
-	buf.WriteString(tr.Content)
-	buf.WriteString(postWrite)
-	return buf.String()
+	return text
 }
 
-func (p *Parser) ParseDocxBlockImage(img *lark.DocxBlockImage) string {
-	buf := new(strings.Builder)
-	buf.WriteString(fmt.Sprintf("![](%s)", img.Token))
-	buf.WriteString("\n")
+func (p *Parser) ParseDocxBlockImage(img *lark.DocxBlockImage) *ast.Image {
 	p.ImgTokens = append(p.ImgTokens, img.Token)
-	return buf.String()
+	return &ast.Image{
+		Token:  img.Token,
+		Src:    img.Token,
+		Width:  int(img.Width),
+		Height: int(img.Height),
+	}
 }
 
-func (p *Parser) ParseDocxWhatever(body *lark.DocBody) string {
-	buf := new(strings.Builder)
-
-	return buf.String()
+func (p *Parser) ParseDocxBlockFile(f *lark.DocxBlockFile) *ast.File {
+	p.FileTokens = append(p.FileTokens, f.Token)
+	return &ast.File{
+		Token: f.Token,
+		Name:  f.Name,
+		Src:   f.Token,
+	}
 }
 
-func (p *Parser) ParseDocxBlockBullet(b *lark.DocxBlock, indentLevel int) string {
-	buf := new(strings.Builder)
-
-	buf.WriteString("- ")
-	buf.WriteString(p.ParseDocxBlockText(b.Bullet))
-
-	for _, childId := range b.Children {
-		childBlock := p.blockMap[childId]
-		buf.WriteString(p.ParseDocxBlock(childBlock, indentLevel+1))
+func (p *Parser) ParseDocxBlockIframe(f *lark.DocxBlockIframe) ast.Block {
+	if f.Component == nil || f.Component.URL == "" {
+		return nil
 	}
+	name := DocxIframeType2Str[f.Component.IframeType]
+	if name == "" {
+		name = "Embed"
+	}
+	return &ast.Iframe{Type: name, URL: utils.UnescapeURL(f.Component.URL)}
+}
 
-	return buf.String()
+func (p *Parser) ParseDocxBlockListItem(b *lark.DocxBlock, text *lark.DocxBlockText) *ast.ListItem {
+	return &ast.ListItem{
+		Content:  p.ParseDocxBlockText(text),
+		Children: p.ParseDocxBlocks(b.Children),
+	}
 }
 
-func (p *Parser) ParseDocxBlockOrdered(b *lark.DocxBlock, indentLevel int) string {
-	buf := new(strings.Builder)
+func (p *Parser) ParseDocxBlockTable(t *lark.DocxBlockTable) ast.Block {
+	// - First row as header
+	// - Cells covered by a merged neighbour are left nil
+	if t.Property == nil || t.Property.ColumnSize <= 0 {
+		return nil
+	}
+	colSize := int(t.Property.ColumnSize)
+	rowSize := (len(t.Cells) + colSize - 1) / colSize
+	rows := make([][]*ast.TableCell, rowSize)
+	covered := make([][]bool, rowSize)
+	for i := range rows {
+		rows[i] = make([]*ast.TableCell, colSize)
+		covered[i] = make([]bool, colSize)
+	}
 
-	// calculate order and indent level
-	parent := p.blockMap[b.ParentID]
-	order := 1
-	for idx, child := range parent.Children {
-		if child == b.BlockID {
-			for i := idx - 1; i >= 0; i-- {
-				if p.blockMap[parent.Children[i]].BlockType == lark.DocxBlockTypeOrdered {
-					order += 1
-				} else {
-					break
+	for idx, blockId := range t.Cells {
+		i, j := idx/colSize, idx%colSize
+		if covered[i][j] {
+			continue
+		}
+		cell := &ast.TableCell{
+			RowSpan:  1,
+			ColSpan:  1,
+			Children: p.ParseDocxBlocks(p.blockMap[blockId].Children),
+		}
+		if idx < len(t.Property.MergeInfo) {
+			if info := t.Property.MergeInfo[idx]; info != nil {
+				if info.RowSpan > 1 {
+					cell.RowSpan = int(info.RowSpan)
+				}
+				if info.ColSpan > 1 {
+					cell.ColSpan = int(info.ColSpan)
+				}
+			}
+		}
+		rows[i][j] = cell
+		for r := i; r < i+cell.RowSpan && r < rowSize; r++ {
+			for c := j; c < j+cell.ColSpan && c < colSize; c++ {
+				if r != i || c != j {
+					covered[r][c] = true
 				}
 			}
-			break
 		}
 	}
 
-	buf.WriteString(fmt.Sprintf("%d. ", order))
-	buf.WriteString(p.ParseDocxBlockText(b.Ordered))
+	return &ast.Table{Rows: rows}
+}
 
-	for _, childId := range b.Children {
-		childBlock := p.blockMap[childId]
-		buf.WriteString(p.ParseDocxBlock(childBlock, indentLevel+1))
+func (p *Parser) ParseDocxBlockCallout(b *lark.DocxBlock) *ast.Callout {
+	emoji := ""
+	if b.Callout.EmojiID != "" {
+		emoji = DocxCalloutEmoji2Str[b.Callout.EmojiID]
+		if emoji == "" {
+			emoji = ":" + b.Callout.EmojiID + ":"
+		}
+	}
+	return &ast.Callout{
+		Emoji:           emoji,
+		BackgroundColor: DocxCalloutColor2Str[b.Callout.BackgroundColor],
+		Children:        p.ParseDocxBlocks(b.Children),
 	}
-
-	return buf.String()
 }
 
-func (p *Parser) ParseDocxBlockTableCell(b *lark.DocxBlock) string {
-	buf := new(strings.Builder)
-
+func (p *Parser) ParseDocxBlockGrid(b *lark.DocxBlock) *ast.Grid {
+	grid := &ast.Grid{}
 	for _, child := range b.Children {
 		block := p.blockMap[child]
-		content := p.ParseDocxBlock(block, 0)
-		buf.WriteString(content)
-	}
-
-	return buf.String()
-}
-
-func (p *Parser) ParseDocxBlockTable(t *lark.DocxBlockTable) string {
-	// - First row as header
-	// - Ignore cell merging
-	var rows [][]string
-	for i, blockId := range t.Cells {
-		block := p.blockMap[blockId]
-		cellContent := p.ParseDocxBlock(block, 0)
-		cellContent = strings.ReplaceAll(cellContent, "\n", "")
-		rowIndex := int64(i) / t.Property.ColumnSize
-		if len(rows) < int(rowIndex)+1 {
-			rows = append(rows, []string{})
+		if block == nil || block.BlockType != lark.DocxBlockTypeGridColumn {
+			continue
 		}
-		rows[rowIndex] = append(rows[rowIndex], cellContent)
+		grid.Columns = append(grid.Columns, p.ParseDocxBlockGridColumn(block))
 	}
-
-	buf := new(strings.Builder)
-	buf.WriteString(renderMarkdownTable(rows))
-	buf.WriteString("\n")
-	return buf.String()
+	return grid
 }
 
-func (p *Parser) ParseDocxBlockQuoteContainer(b *lark.DocxBlock) string {
-	buf := new(strings.Builder)
-
-	for _, child := range b.Children {
-		block := p.blockMap[child]
-		buf.WriteString("> ")
-		buf.WriteString(p.ParseDocxBlock(block, 0))
+func (p *Parser) ParseDocxBlockGridColumn(b *lark.DocxBlock) *ast.GridColumn {
+	column := &ast.GridColumn{Children: p.ParseDocxBlocks(b.Children)}
+	if b.GridColumn != nil {
+		column.WidthRatio = int(b.GridColumn.WidthRatio)
 	}
-
-	return buf.String()
+	return column
 }
@@ -1,15 +1,16 @@
 package core_test
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"strings"
 	"testing"
 
 	"github.com/88250/lute"
+	"github.com/Wsine/feishu2md/ast"
 	"github.com/Wsine/feishu2md/core"
 	"github.com/Wsine/feishu2md/utils"
 	"github.com/chyroc/lark"
@@ -40,8 +41,9 @@ func TestParseDocxContent(t *testing.T) {
 			byteValue, _ := ioutil.ReadAll(jsonFile)
 			json.Unmarshal(byteValue, &data)
 
-			parser := core.NewParser(context.Background())
-			mdParsed := parser.ParseDocxContent(data.Document, data.Blocks)
+			parser := core.NewParser()
+			document := parser.ParseDocxContent(data.Document, data.Blocks)
+			mdParsed := core.NewMarkdownRenderer(core.NewConfig("", "").Output).Render(document)
 			fmt.Println(mdParsed)
 			mdParsed = engine.FormatStr("md", mdParsed)
 
@@ -53,3 +55,651 @@ func TestParseDocxContent(t *testing.T) {
 		})
 	}
 }
+
+func newTextElements(content string) *lark.DocxBlockText {
+	return &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+		{TextRun: &lark.DocxTextElementTextRun{Content: content}},
+	}}
+}
+
+func newPageBlock(title string, children ...string) *lark.DocxBlock {
+	return &lark.DocxBlock{
+		BlockID:   "doc",
+		BlockType: lark.DocxBlockTypePage,
+		Page:      newTextElements(title),
+		Children:  children,
+	}
+}
+
+func newTextBlock(id, parentID, content string) *lark.DocxBlock {
+	return &lark.DocxBlock{
+		BlockID:   id,
+		ParentID:  parentID,
+		BlockType: lark.DocxBlockTypeText,
+		Text:      newTextElements(content),
+	}
+}
+
+func newTableBlock(rows, cols int64, cells ...string) *lark.DocxBlock {
+	return &lark.DocxBlock{
+		BlockID:   "table",
+		ParentID:  "doc",
+		BlockType: lark.DocxBlockTypeTable,
+		Table: &lark.DocxBlockTable{
+			Cells:    cells,
+			Property: &lark.DocxBlockTableProperty{RowSize: rows, ColumnSize: cols},
+		},
+		Children: cells,
+	}
+}
+
+func newCellBlock(id string, children ...string) *lark.DocxBlock {
+	return &lark.DocxBlock{
+		BlockID:   id,
+		ParentID:  "table",
+		BlockType: lark.DocxBlockTypeTableCell,
+		TableCell: &lark.DocxBlockTableCell{},
+		Children:  children,
+	}
+}
+
+// renderDocx parses the blocks of a document and renders it as markdown
+func renderDocx(t *testing.T, output core.OutputConfig, blocks ...*lark.DocxBlock) string {
+	t.Helper()
+	parser := core.NewParser()
+	document := parser.ParseDocxContent(&lark.DocxDocument{DocumentID: "doc"}, blocks)
+	return core.NewMarkdownRenderer(output).Render(document)
+}
+
+func TestParseDocxBlockCallout(t *testing.T) {
+	blocks := []*lark.DocxBlock{
+		newPageBlock("Callout", "callout"),
+		{
+			BlockID:   "callout",
+			ParentID:  "doc",
+			BlockType: lark.DocxBlockTypeCallout,
+			Callout: &lark.DocxBlockCallout{
+				BackgroundColor: lark.DocxCalloutBackgroundColorLightRed,
+				EmojiID:         "warning",
+			},
+			Children: []string{"text1", "text2"},
+		},
+		newTextBlock("text1", "callout", "Do not"),
+		newTextBlock("text2", "callout", "run this"),
+	}
+
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{core.CalloutStyleBlockquote, "> ⚠️ Do not\n>\n> run this\n"},
+		{core.CalloutStyleGitHub, "> [!CAUTION]\n> ⚠️ Do not\n>\n> run this\n"},
+		{core.CalloutStyleMkDocs, "!!! danger \"⚠️\"\n    Do not\n\n    run this\n"},
+		{core.CalloutStyleDocusaurus, ":::danger ⚠️\n\nDo not\n\nrun this\n\n:::\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			output := core.NewConfig("", "").Output
+			output.CalloutStyle = tt.style
+			assert.Equal(t, "# Callout\n\n"+tt.want+"\n", renderDocx(t, output, blocks...))
+		})
+	}
+}
+
+func TestRenderCalloutInList(t *testing.T) {
+	paragraph := func(content string) *ast.Paragraph {
+		return &ast.Paragraph{Content: []ast.Inline{&ast.Text{Content: content}}}
+	}
+	document := &ast.Document{Children: []ast.Block{
+		&ast.List{Ordered: true, Items: []*ast.ListItem{{
+			Content: []ast.Inline{&ast.Text{Content: "a"}},
+			Children: []ast.Block{&ast.Callout{
+				BackgroundColor: "light-blue",
+				Children:        []ast.Block{paragraph("x"), paragraph("y")},
+			}},
+		}}},
+	}}
+
+	mdParsed := core.NewMarkdownRenderer(core.NewConfig("", "").Output).Render(document)
+	assert.Equal(t, "1. a\n\t> x\n\t>\n\t> y\n\n", mdParsed)
+	engine := lute.New()
+	assert.Equal(t, "<ol>\n<li>a\n<blockquote>\n<p>x</p>\n<p>y</p>\n</blockquote>\n</li>\n</ol>\n",
+		engine.Md2HTML(mdParsed))
+}
+
+func TestParseDocxBlockGrid(t *testing.T) {
+	blocks := []*lark.DocxBlock{
+		newPageBlock("Grid", "grid"),
+		{
+			BlockID:   "grid",
+			ParentID:  "doc",
+			BlockType: lark.DocxBlockTypeGrid,
+			Grid:      &lark.DocxBlockGrid{ColumnSize: 2},
+			Children:  []string{"col1", "col2"},
+		},
+		{
+			BlockID:    "col1",
+			ParentID:   "grid",
+			BlockType:  lark.DocxBlockTypeGridColumn,
+			GridColumn: &lark.DocxBlockGridColumn{WidthRatio: 30},
+			Children:   []string{"text1", "text2"},
+		},
+		{
+			BlockID:    "col2",
+			ParentID:   "grid",
+			BlockType:  lark.DocxBlockTypeGridColumn,
+			GridColumn: &lark.DocxBlockGridColumn{WidthRatio: 70},
+			Children:   []string{"text3"},
+		},
+		newTextBlock("text1", "col1", "Left 1"),
+		newTextBlock("text2", "col1", "Left 2"),
+		newTextBlock("text3", "col2", "Right"),
+	}
+
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{core.GridStyleFlatten, "Left 1\n\nLeft 2\n\nRight\n"},
+		{core.GridStyleHTML, "<div style=\"display:flex\">\n" +
+			"<div style=\"width:30%\">\n\nLeft 1\n\nLeft 2\n\n</div>\n" +
+			"<div style=\"width:70%\">\n\nRight\n\n</div>\n" +
+			"</div>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			output := core.NewConfig("", "").Output
+			output.GridStyle = tt.style
+			assert.Equal(t, "# Grid\n\n"+tt.want+"\n", renderDocx(t, output, blocks...))
+		})
+	}
+}
+
+func TestParseDocxBlockTableMerged(t *testing.T) {
+	cells := []string{"c1", "c2", "c3", "c4", "c5", "c6"}
+	contents := []string{"A", "B", "", "C", "D", "E"}
+	table := newTableBlock(3, 2, cells...)
+	table.Table.Property.MergeInfo = []*lark.DocxBlockTablePropertyMergeInfo{
+		{RowSpan: 1, ColSpan: 2}, {RowSpan: 1, ColSpan: 1},
+		{RowSpan: 2, ColSpan: 1}, {RowSpan: 1, ColSpan: 1},
+		{RowSpan: 1, ColSpan: 1}, {RowSpan: 1, ColSpan: 1},
+	}
+	blocks := []*lark.DocxBlock{newPageBlock("Table", "table"), table}
+	for i, id := range cells {
+		blocks = append(blocks,
+			newCellBlock(id, id+"_text"),
+			newTextBlock(id+"_text", id, contents[i]),
+		)
+	}
+
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{core.TableStyleAuto, "<table>\n" +
+			"<tr>\n<th colspan=\"2\">A</th>\n</tr>\n" +
+			"<tr>\n<td rowspan=\"2\"></td>\n<td>C</td>\n</tr>\n" +
+			"<tr>\n<td>E</td>\n</tr>\n" +
+			"</table>\n"},
+		{core.TableStyleMarkdown, "| A |   |\n" +
+			"|---|---|\n" +
+			"|   | C |\n" +
+			"|   | E |\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			output := core.NewConfig("", "").Output
+			output.TableStyle = tt.style
+			assert.Equal(t, "# Table\n\n"+tt.want+"\n\n", renderDocx(t, output, blocks...))
+		})
+	}
+}
+
+func TestRenderTableMergedInlines(t *testing.T) {
+	paragraph := func(inlines ...ast.Inline) *ast.TableCell {
+		return &ast.TableCell{RowSpan: 1, ColSpan: 1, Children: []ast.Block{
+			&ast.Paragraph{Content: inlines},
+		}}
+	}
+	head := paragraph(&ast.Text{Content: "Head"})
+	head.ColSpan = 2
+	table := &ast.Table{Rows: [][]*ast.TableCell{
+		{head, nil},
+		{
+			paragraph(
+				&ast.Text{Content: "bold", Marks: ast.Bold},
+				&ast.Text{Content: " and "},
+				&ast.Text{Content: "link", Link: "https://example.com/?a=1&b=2"},
+			),
+			paragraph(&ast.Text{Content: "a < b"}),
+		},
+	}}
+
+	mdParsed := core.NewMarkdownRenderer(core.NewConfig("", "").Output).RenderTable(table)
+	assert.Equal(t, "<table>\n"+
+		"<tr>\n<th colspan=\"2\">Head</th>\n</tr>\n"+
+		"<tr>\n<td><strong>bold</strong> and <a href=\"https://example.com/?a=1&amp;b=2\">link</a></td>\n<td>a &lt; b</td>\n</tr>\n"+
+		"</table>\n\n", mdParsed)
+}
+
+func TestParseDocxBlockTableCellContent(t *testing.T) {
+	header := []*lark.DocxBlock{
+		newPageBlock("Table", "table"),
+		newTableBlock(2, 1, "head", "body"),
+		newCellBlock("head", "head_text"),
+		newTextBlock("head_text", "head", "Notes"),
+	}
+	output := core.NewConfig("", "").Output
+
+	t.Run("markdown", func(t *testing.T) {
+		blocks := append(header,
+			newCellBlock("body", "p1", "p2", "li1", "li2"),
+			newTextBlock("p1", "body", "a | b"),
+			newTextBlock("p2", "body", "second"),
+			&lark.DocxBlock{
+				BlockID:   "li1",
+				ParentID:  "body",
+				BlockType: lark.DocxBlockTypeBullet,
+				Bullet:    newTextElements("one"),
+			},
+			&lark.DocxBlock{
+				BlockID:   "li2",
+				ParentID:  "body",
+				BlockType: lark.DocxBlockTypeBullet,
+				Bullet:    newTextElements("two"),
+			},
+		)
+		assert.Equal(t, "# Table\n\n"+
+			"|                       Notes                       |\n"+
+			"|---------------------------------------------------|\n"+
+			"| a \\| b<br>second<ul><li>one</li><li>two</li></ul> |\n"+
+			"\n\n", renderDocx(t, output, blocks...))
+	})
+
+	t.Run("html", func(t *testing.T) {
+		blocks := append(header,
+			newCellBlock("body", "code"),
+			&lark.DocxBlock{
+				BlockID:   "code",
+				ParentID:  "body",
+				BlockType: lark.DocxBlockTypeCode,
+				Code:      newTextElements("if a < b {\n\treturn\n}"),
+			},
+		)
+		assert.Equal(t, "# Table\n\n"+
+			"<table>\n"+
+			"<tr>\n<th>Notes</th>\n</tr>\n"+
+			"<tr>\n<td><pre><code>if a &lt; b {<br>\treturn<br>}</code></pre></td>\n</tr>\n"+
+			"</table>\n"+
+			"\n\n", renderDocx(t, output, blocks...))
+	})
+}
+
+func TestParseDocxBlockTableWithoutColumns(t *testing.T) {
+	output := core.NewConfig("", "").Output
+	assert.Equal(t, "# Table\n\n", renderDocx(t, output,
+		newPageBlock("Table", "table"),
+		newTableBlock(0, 0),
+	))
+}
+
+func TestRenderTableCellBlocks(t *testing.T) {
+	paragraph := func(content string) *ast.Paragraph {
+		return &ast.Paragraph{Content: []ast.Inline{&ast.Text{Content: content}}}
+	}
+	cell := func(b ast.Block) *ast.TableCell {
+		return &ast.TableCell{RowSpan: 1, ColSpan: 1, Children: []ast.Block{b}}
+	}
+
+	tests := []struct {
+		name  string
+		block ast.Block
+		want  string
+	}{
+		{"paragraph", paragraph("p"), "| p    |"},
+		{"list", &ast.List{Items: []*ast.ListItem{
+			{Content: []ast.Inline{&ast.Text{Content: "li"}}},
+		}}, "| <ul><li>li</li></ul> |"},
+		{"image", &ast.Image{Token: "boxcn1", Src: "a.png"}, "| ![](a.png) |"},
+		{"file", &ast.File{Token: "boxcn2", Name: "a.pdf", Src: "a.pdf"}, "| [a.pdf](a.pdf) |"},
+		{"heading", &ast.Heading{Level: 2, Content: []ast.Inline{&ast.Text{Content: "Title"}}}, "<h2>Title</h2>"},
+		{"blockquote", &ast.Blockquote{Children: []ast.Block{paragraph("q1"), paragraph("q2")}}, "<blockquote><p>q1</p><p>q2</p></blockquote>"},
+		{"callout", &ast.Callout{BackgroundColor: "light-blue", Children: []ast.Block{paragraph("c")}}, "<p>c</p>"},
+		{"math", &ast.Math{Content: "x^2\n+1"}, "<div class=\"math\">\\[x^2 +1\\]</div>"},
+		{"divider", &ast.ThematicBreak{}, "<hr>"},
+		{"grid", &ast.Grid{Columns: []*ast.GridColumn{{WidthRatio: 100, Children: []ast.Block{paragraph("g")}}}}, "<p>g</p>"},
+		{"table", &ast.Table{Rows: [][]*ast.TableCell{{cell(paragraph("inner"))}}}, "<th>inner</th>"},
+		{"code", &ast.Code{Content: []ast.Inline{&ast.Text{Content: "x"}}}, "<pre><code>x</code></pre>"},
+		{"list with a heading", &ast.List{Items: []*ast.ListItem{{
+			Content:  []ast.Inline{&ast.Text{Content: "li"}},
+			Children: []ast.Block{&ast.Heading{Level: 3, Content: []ast.Inline{&ast.Text{Content: "h"}}}},
+		}}}, "<h3>h</h3>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &ast.Table{Rows: [][]*ast.TableCell{
+				{cell(paragraph("Head"))},
+				{cell(tt.block)},
+			}}
+			output := core.NewConfig("", "").Output
+			mdParsed := core.NewMarkdownRenderer(output).RenderTable(table)
+			// the blocks that fit in a GFM table are checked by their row
+			fits := strings.HasPrefix(tt.want, "|")
+			assert.Equal(t, !fits, strings.HasPrefix(mdParsed, "<table>\n"), mdParsed)
+			assert.Contains(t, mdParsed, tt.want)
+			// the HTML table survives the formatting
+			assert.Contains(t, core.PrettifyMarkdown(mdParsed, output), tt.want)
+		})
+	}
+}
+
+func TestParseDocxBlockImage(t *testing.T) {
+	blocks := []*lark.DocxBlock{
+		newPageBlock("Image", "image"),
+		{
+			BlockID:   "image",
+			ParentID:  "doc",
+			BlockType: lark.DocxBlockTypeImage,
+			Image:     &lark.DocxBlockImage{Token: "boxcn1", Width: 640, Height: 480},
+		},
+	}
+
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{core.ImageStyleMarkdown, "![](boxcn1)\n"},
+		{core.ImageStyleHTML, "<img src=\"boxcn1\" width=\"640\" height=\"480\">\n"},
+		{core.ImageStylePandoc, "![](boxcn1){width=640px height=480px}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			output := core.NewConfig("", "").Output
+			output.ImageStyle = tt.style
+			assert.Equal(t, "# Image\n\n"+tt.want+"\n", renderDocx(t, output, blocks...))
+		})
+	}
+}
+
+func TestParseDocxBlockImageProps(t *testing.T) {
+	// an item of the block list, decoded as by Client.GetDocxContent
+	raw := []byte(`{"block_id":"image","parent_id":"doc","block_type":27,"image":` +
+		`{"token":"boxcn1","width":640,"height":480,"align":2,"caption":{"content":"A [b] & c"}}}`)
+	block := new(lark.DocxBlock)
+	assert.Nil(t, json.Unmarshal(raw, block))
+	props := new(core.DocxBlockProps)
+	assert.Nil(t, json.Unmarshal(raw, props))
+
+	parser := core.NewParser()
+	parser.BlockProps = map[string]*core.DocxBlockProps{"image": props}
+	document := parser.ParseDocxContent(
+		&lark.DocxDocument{DocumentID: "doc"},
+		[]*lark.DocxBlock{newPageBlock("Image", "image"), block},
+	)
+	assert.Equal(t, &ast.Image{
+		Token: "boxcn1", Src: "boxcn1", Width: 640, Height: 480,
+		Align: "center", Caption: "A [b] & c",
+	}, document.Children[1])
+}
+
+func TestRenderImageProps(t *testing.T) {
+	document := &ast.Document{Title: "Image", Children: []ast.Block{
+		&ast.Heading{Level: 1, Content: []ast.Inline{&ast.Text{Content: "Image"}}},
+		&ast.Image{
+			Token: "boxcn1", Src: "boxcn1", Width: 640, Height: 480,
+			Align: "center", Caption: "A [b] & c",
+		},
+	}}
+
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{core.ImageStyleMarkdown, "![A \\[b\\] & c](boxcn1)\n"},
+		{
+			core.ImageStyleHTML,
+			"<p align=\"center\"><img src=\"boxcn1\" alt=\"A [b] &amp; c\" width=\"640\" height=\"480\"></p>\n",
+		},
+		{core.ImageStylePandoc, "![A \\[b\\] & c](boxcn1){width=640px height=480px}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			output := core.NewConfig("", "").Output
+			output.ImageStyle = tt.style
+			mdParsed := core.NewMarkdownRenderer(output).Render(document)
+			assert.Equal(t, "# Image\n\n"+tt.want+"\n", mdParsed)
+		})
+	}
+
+	htmlParsed := core.NewHTMLRenderer(core.NewConfig("", "").Output).RenderBlocks(document.Children)
+	assert.Contains(t, htmlParsed, "<figure style=\"text-align:center\">"+
+		"<img src=\"boxcn1\" alt=\"A [b] &amp; c\" width=\"640\" height=\"480\">"+
+		"<figcaption>A [b] &amp; c</figcaption></figure>\n")
+}
+
+func TestParseDocxBlockFile(t *testing.T) {
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+	blocks := []*lark.DocxBlock{
+		newPageBlock("File", "view"),
+		{
+			BlockID:   "view",
+			ParentID:  "doc",
+			BlockType: lark.DocxBlockTypeView,
+			Children:  []string{"file"},
+		},
+		{
+			BlockID:   "file",
+			ParentID:  "view",
+			BlockType: lark.DocxBlockTypeFile,
+			File:      &lark.DocxBlockFile{Token: "boxcn2", Name: "spec.pdf"},
+		},
+	}
+
+	parser := core.NewParser()
+	document := parser.ParseDocxContent(doc, blocks)
+	assert.Equal(t, []string{"boxcn2"}, parser.FileTokens)
+
+	renderer := core.NewMarkdownRenderer(core.NewConfig("", "").Output)
+	assert.Equal(t, "# File\n\n[spec.pdf](boxcn2)\n\n", renderer.Render(document))
+
+	file := document.Children[1].(*ast.File)
+	file.Src, file.Size = "attachments/boxcn2/spec.pdf", 1536
+	assert.Equal(t, "# File\n\n[spec.pdf](attachments/boxcn2/spec.pdf) (1.5 KB)\n\n", renderer.Render(document))
+}
+
+func TestParseDocxBlockIframe(t *testing.T) {
+	blocks := []*lark.DocxBlock{
+		newPageBlock("Iframe", "iframe"),
+		{
+			BlockID:   "iframe",
+			ParentID:  "doc",
+			BlockType: lark.DocxBlockTypeIframe,
+			Iframe: &lark.DocxBlockIframe{Component: &lark.DocxBlockIframeComponent{
+				IframeType: lark.DocxIframeComponentTypeFigma,
+				URL:        "https%3A%2F%2Fwww.figma.com%2Ffile%2Fabc",
+			}},
+		},
+	}
+
+	tests := []struct {
+		useHTMLTags bool
+		want        string
+	}{
+		{false, "[Figma](https://www.figma.com/file/abc)\n"},
+		{true, "<iframe src=\"https://www.figma.com/file/abc\" title=\"Figma\" width=\"100%\" height=\"480\" frameborder=\"0\" allowfullscreen></iframe>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.useHTMLTags), func(t *testing.T) {
+			output := core.NewConfig("", "").Output
+			output.UseHTMLTags = tt.useHTMLTags
+			assert.Equal(t, "# Iframe\n\n"+tt.want+"\n", renderDocx(t, output, blocks...))
+		})
+	}
+}
+
+func TestParseDocxBlockDiagram(t *testing.T) {
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+	blocks := []*lark.DocxBlock{
+		newPageBlock("Diagram", "diagram", "mindnote", "board"),
+		{
+			BlockID:   "diagram",
+			ParentID:  "doc",
+			BlockType: lark.DocxBlockTypeDiagram,
+			Diagram:   &lark.DocxBlockDiagram{DiagramType: 1},
+		},
+		{
+			BlockID:   "mindnote",
+			ParentID:  "doc",
+			BlockType: lark.DocxBlockTypeMindnote,
+			Mindnote:  &lark.DocxBlockMindnote{Token: "bmncn1"},
+		},
+		{
+			BlockID:   "board",
+			ParentID:  "doc",
+			BlockType: lark.DocxBlockType(43),
+		},
+	}
+
+	parser := core.NewParser()
+	parser.BlockProps = map[string]*core.DocxBlockProps{
+		"board": {Board: &core.DocxBoardProps{Token: "wbcn1"}},
+	}
+	document := parser.ParseDocxContent(doc, blocks)
+	assert.Equal(t, "wbcn1", document.Children[3].(*ast.Diagram).Token)
+	core.LinkDiagramSources(document, "https://sample.feishu.cn/docx/doc")
+	document.Children[3].(*ast.Diagram).Src = "static/board.png"
+
+	renderer := core.NewMarkdownRenderer(core.NewConfig("", "").Output)
+	assert.Equal(t, "# Diagram\n\n"+
+		"[Diagram (not exported)](https://sample.feishu.cn/docx/doc#diagram)\n\n"+
+		"[Mindnote (not exported)](https://sample.feishu.cn/docx/doc#mindnote)\n\n"+
+		"![](static/board.png)\n\n", renderer.Render(document))
+}
+
+func TestRenderTableCellContentHTML(t *testing.T) {
+	cell := &ast.TableCell{RowSpan: 1, ColSpan: 1, Children: []ast.Block{
+		&ast.Paragraph{Content: []ast.Inline{
+			&ast.Text{Content: "see "},
+			&ast.Text{Content: "docs", Link: "https://example.com/docs"},
+		}},
+		&ast.List{Items: []*ast.ListItem{
+			{Content: []ast.Inline{&ast.Text{Content: "one", Marks: ast.Bold}}},
+			{
+				Content: []ast.Inline{&ast.Text{Content: "a & b"}},
+				Children: []ast.Block{&ast.Paragraph{Content: []ast.Inline{
+					&ast.Text{Content: "more", Marks: ast.Italic},
+				}}},
+			},
+		}},
+		&ast.Code{Content: []ast.Inline{&ast.Text{Content: "x := 1", Marks: ast.Bold}}},
+	}}
+
+	renderer := core.NewMarkdownRenderer(core.NewConfig("", "").Output)
+	assert.Equal(t, "see <a href=\"https://example.com/docs\">docs</a>"+
+		"<ul><li><strong>one</strong></li><li>a &amp; b<br><em>more</em></li></ul>"+
+		"<pre><code>x := 1</code></pre>", renderer.RenderTableCell(cell, true))
+	assert.Equal(t, "see [docs](https://example.com/docs)"+
+		"<ul><li>**one**</li><li>a & b<br>_more_</li></ul>"+
+		"<pre><code>x := 1</code></pre>", renderer.RenderTableCell(cell, false))
+}
+
+func TestRenderUserMention(t *testing.T) {
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+	blocks := []*lark.DocxBlock{
+		newPageBlock("Mention", "text"),
+		{
+			BlockID:   "text",
+			ParentID:  "doc",
+			BlockType: lark.DocxBlockTypeText,
+			Text: &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+				{TextRun: &lark.DocxTextElementTextRun{Content: "ping "}},
+				{MentionUser: &lark.DocxTextElementMentionUser{UserID: "ou_1"}},
+				{TextRun: &lark.DocxTextElementTextRun{Content: " and "}},
+				{MentionUser: &lark.DocxTextElementMentionUser{UserID: "ou_2"}},
+			}},
+		},
+	}
+	parser := core.NewParser()
+	document := parser.ParseDocxContent(doc, blocks)
+	// ou_2 could not be resolved
+	ast.Walk(document, func(n ast.Node) bool {
+		if m, ok := n.(*ast.UserMention); ok && m.UserID == "ou_1" {
+			m.Name, m.Email = "Alice", "alice@example.com"
+		}
+		return true
+	})
+
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{core.MentionStyleID, "ping ou_1 and ou_2\n"},
+		{core.MentionStyleName, "ping @Alice and ou_2\n"},
+		{core.MentionStyleMailto, "ping [@Alice](mailto:alice@example.com) and ou_2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			output := core.NewConfig("", "").Output
+			output.MentionStyle = tt.style
+			mdParsed := core.NewMarkdownRenderer(output).Render(document)
+			assert.Equal(t, "# Mention\n\n"+tt.want+"\n", mdParsed)
+		})
+	}
+}
+
+func TestRenderUserMentionFormatted(t *testing.T) {
+	engine := lute.New()
+	document := &ast.Document{Children: []ast.Block{
+		&ast.Paragraph{Content: []ast.Inline{
+			&ast.Text{Content: "请"},
+			&ast.UserMention{UserID: "ou_1", Name: "张三", Email: "zhangsan@example.com"},
+			&ast.Text{Content: "确认"},
+		}},
+	}}
+
+	tests := []struct {
+		style  string
+		want   string
+		output string
+	}{
+		{core.MentionStyleName, "请@张三确认\n", "<p>请@张三确认</p>\n"},
+		{
+			core.MentionStyleMailto,
+			"请[@张三](mailto:zhangsan@example.com)确认\n",
+			"<p>请<a href=\"mailto:zhangsan@example.com\">@张三</a>确认</p>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			output := core.NewConfig("", "").Output
+			output.MentionStyle = tt.style
+			mdParsed := core.PrettifyMarkdown(core.NewMarkdownRenderer(output).Render(document), output)
+			assert.Equal(t, tt.want, mdParsed)
+			assert.Equal(t, tt.output, engine.Md2HTML(mdParsed))
+		})
+	}
+}
+
+func TestPrettifyMarkdownAdmonition(t *testing.T) {
+	paragraph := func(content string) *ast.Paragraph {
+		return &ast.Paragraph{Content: []ast.Inline{&ast.Text{Content: content}}}
+	}
+	document := &ast.Document{Children: []ast.Block{
+		paragraph("中文abc"),
+		&ast.Callout{
+			BackgroundColor: "light-blue",
+			Children:        []ast.Block{paragraph("Do not"), paragraph("run this")},
+		},
+		&ast.Table{Rows: [][]*ast.TableCell{
+			{{RowSpan: 1, ColSpan: 1, Children: []ast.Block{paragraph("Name")}}},
+			{{RowSpan: 1, ColSpan: 1, Children: []ast.Block{paragraph("a")}}},
+		}},
+	}}
+
+	output := core.NewConfig("", "").Output
+	output.CalloutStyle = core.CalloutStyleMkDocs
+	mdParsed := core.PrettifyMarkdown(core.NewMarkdownRenderer(output).Render(document), output)
+	assert.Equal(t, "中文 abc\n\n"+
+		"!!! note\n    Do not\n\n    run this\n\n"+
+		"| Name |\n| ---- |\n| a    |\n", mdParsed)
+}
@@ -0,0 +1,34 @@
+package core
+
+import (
+	"github.com/Wsine/feishu2md/ast"
+	"github.com/pkg/errors"
+)
+
+// Renderer turns a document tree into the content of an output file.
+type Renderer interface {
+	Render(doc *ast.Document) string
+}
+
+// Supported output formats
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// FormatExtensions maps an output format to its file extension.
+var FormatExtensions = map[string]string{
+	FormatMarkdown: ".md",
+	FormatHTML:     ".html",
+}
+
+func NewRenderer(format string, conf OutputConfig) (Renderer, error) {
+	switch format {
+	case FormatMarkdown:
+		return NewMarkdownRenderer(conf), nil
+	case FormatHTML:
+		return NewHTMLRenderer(conf), nil
+	default:
+		return nil, errors.Errorf("Unsupported output format: %s", format)
+	}
+}
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chyroc/lark"
+)
+
+// retryableCodes are the error codes of the OPEN API worth another try,
+// 99991400 is returned when the frequency limit is hit.
+var retryableCodes = map[int64]bool{
+	99991400: true,
+}
+
+// retryDelay tells whether a failed request should be retried and how long
+// to wait before that. The delay asked by the server wins over the backoff.
+func retryDelay(conf RequestConfig, attempt int, resp *lark.Response, err error) (time.Duration, bool) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return 0, false
+	}
+
+	// an overloaded server may answer with a JSON error body too, such
+	// statuses are retried whatever the error code
+	serverBusy := resp != nil &&
+		(resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500)
+	if !serverBusy {
+		var larkErr *lark.Error
+		if errors.As(err, &larkErr) {
+			if !retryableCodes[larkErr.Code] {
+				return 0, false
+			}
+		} else if resp != nil && resp.StatusCode != 0 {
+			// the server answered, but not with something a retry would fix
+			return 0, false
+		}
+	}
+
+	if delay, ok := serverRetryDelay(resp); ok {
+		return delay, true
+	}
+
+	// exponential backoff with jitter in [delay/2, delay]
+	delay := time.Duration(conf.BaseDelayMs) * time.Millisecond
+	maxDelay := time.Duration(conf.MaxDelayMs) * time.Millisecond
+	for i := 0; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	if delay <= 0 {
+		return 0, true
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1)), true
+}
+
+// serverRetryDelay reads the standard Retry-After header, or the reset time
+// of the frequency limit gateway of the OPEN API.
+func serverRetryDelay(resp *lark.Response) (time.Duration, bool) {
+	if resp == nil || resp.Header == nil {
+		return 0, false
+	}
+	if value := resp.Header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			if delay := time.Until(date); delay > 0 {
+				return delay, true
+			}
+			return 0, true
+		}
+	}
+	if value := resp.Header.Get("X-Ogw-Ratelimit-Reset"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return 0, false
+}
+
+// rateLimiter is a token bucket shared by all the requests of a client, a
+// nil limiter does not limit anything.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+var rateLimiters = struct {
+	sync.Mutex
+	m map[string]*rateLimiter
+}{m: make(map[string]*rateLimiter)}
+
+// sharedRateLimiter returns the same limiter for the clients of an app, the
+// frequency limits of the OPEN API are counted per app rather than per
+// client. The config of the first client wins.
+func sharedRateLimiter(key string, conf RequestConfig) *rateLimiter {
+	rateLimiters.Lock()
+	defer rateLimiters.Unlock()
+	if l, ok := rateLimiters.m[key]; ok {
+		return l
+	}
+	l := newRateLimiter(conf.RateLimit, conf.RateBurst)
+	rateLimiters.m[key] = l
+	return l
+}
+
+// Wait blocks until a token is available or the context is done.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// do sends a request through the rate limiter and retries it on transient
+// failures, up to the configured number of retries.
+func (c *Client) do(ctx context.Context, request func() (*lark.Response, error)) error {
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		resp, err := request()
+		if err == nil {
+			return nil
+		}
+		delay, retry := retryDelay(c.requestConf, attempt, resp, err)
+		if !retry || attempt >= c.requestConf.MaxRetries {
+			return err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
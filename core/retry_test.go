@@ -0,0 +1,97 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/chyroc/lark"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryDelay(t *testing.T) {
+	conf := RequestConfig{MaxRetries: 3, BaseDelayMs: 100, MaxDelayMs: 1000}
+	rateLimited := lark.NewError("Drive", "GetDocxDocument", 99991400, "request trigger frequency limit")
+	forbidden := lark.NewError("Drive", "GetDocxDocument", 1770032, "forbidden")
+
+	tests := []struct {
+		name    string
+		attempt int
+		resp    *lark.Response
+		err     error
+		retry   bool
+		min     time.Duration
+		max     time.Duration
+	}{
+		{"rate limited", 0, nil, rateLimited, true, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"backoff grows", 2, nil, rateLimited, true, 200 * time.Millisecond, 400 * time.Millisecond},
+		{"backoff is capped", 10, nil, rateLimited, true, 500 * time.Millisecond, time.Second},
+		{"other codes fail", 0, nil, forbidden, false, 0, 0},
+		{"network error", 0, nil, errors.New("connection reset"), true, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"server error", 0, &lark.Response{StatusCode: http.StatusBadGateway}, errors.New("request fail"), true, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"client error", 0, &lark.Response{StatusCode: http.StatusNotFound}, errors.New("request fail"), false, 0, 0},
+		{"server error with code", 0, &lark.Response{StatusCode: http.StatusServiceUnavailable}, forbidden, true, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"code with status ok", 0, &lark.Response{StatusCode: http.StatusOK}, forbidden, false, 0, 0},
+		{"canceled", 0, nil, context.Canceled, false, 0, 0},
+		{
+			"retry after header", 0,
+			&lark.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"3"}}},
+			errors.New("request fail"), true, 3 * time.Second, 3 * time.Second,
+		},
+		{
+			"rate limit reset header", 0,
+			&lark.Response{StatusCode: http.StatusOK, Header: http.Header{"X-Ogw-Ratelimit-Reset": []string{"2"}}},
+			rateLimited, true, 2 * time.Second, 2 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, retry := retryDelay(conf, tt.attempt, tt.resp, tt.err)
+			assert.Equal(t, tt.retry, retry)
+			assert.GreaterOrEqual(t, delay, tt.min)
+			assert.LessOrEqual(t, delay, tt.max)
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	assert.Nil(t, (*rateLimiter)(nil).Wait(context.Background()))
+
+	l := newRateLimiter(20, 2)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, l.Wait(context.Background()))
+	}
+	// the burst is free, the other two wait for 1/20s each
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, l.Wait(ctx))
+}
+
+func TestClientDoRetries(t *testing.T) {
+	c := &Client{requestConf: RequestConfig{MaxRetries: 2, BaseDelayMs: 1, MaxDelayMs: 1}}
+	rateLimited := lark.NewError("Drive", "GetDocxDocument", 99991400, "request trigger frequency limit")
+
+	calls := 0
+	err := c.do(context.Background(), func() (*lark.Response, error) {
+		calls++
+		if calls < 3 {
+			return nil, rateLimited
+		}
+		return nil, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = c.do(context.Background(), func() (*lark.Response, error) {
+		calls++
+		return nil, rateLimited
+	})
+	assert.Equal(t, rateLimited, err)
+	assert.Equal(t, 3, calls)
+}
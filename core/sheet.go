@@ -0,0 +1,211 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Wsine/feishu2md/ast"
+	"github.com/chyroc/lark"
+)
+
+type getSheetValuesReq struct {
+	SpreadSheetToken     string `path:"spreadsheetToken" json:"-"`
+	Range                string `path:"range" json:"-"`
+	ValueRenderOption    string `query:"valueRenderOption" json:"-"`
+	DateTimeRenderOption string `query:"dateTimeRenderOption" json:"-"`
+}
+
+// getSheetValuesResp keeps the cells raw, the SheetContent of the SDK
+// cannot decode decimal or negative numbers.
+type getSheetValuesResp struct {
+	Code int64  `json:"code,omitempty"`
+	Msg  string `json:"msg,omitempty"`
+	Data *struct {
+		ValueRange *struct {
+			Values [][]json.RawMessage `json:"values,omitempty"`
+		} `json:"valueRange,omitempty"`
+	} `json:"data,omitempty"`
+}
+
+// GetSheetValues returns the formatted values of the sheet embedded in a
+// docx, whose token is <spreadsheet token>_<sheet id>. The trailing empty
+// rows and columns are trimmed.
+func (c *Client) GetSheetValues(ctx context.Context, sheetToken string) ([][]string, error) {
+	i := strings.LastIndex(sheetToken, "_")
+	if i <= 0 || i == len(sheetToken)-1 {
+		return nil, fmt.Errorf("invalid sheet token: %s", sheetToken)
+	}
+	resp := new(getSheetValuesResp)
+	err := c.do(ctx, func() (*lark.Response, error) {
+		return c.larkClient.RawRequest(ctx, &lark.RawRequestReq{
+			Scope:  "Drive",
+			API:    "GetSheetValue",
+			Method: "GET",
+			URL:    c.baseURL + "/open-apis/sheets/v2/spreadsheets/:spreadsheetToken/values/:range",
+			Body: &getSheetValuesReq{
+				SpreadSheetToken:     sheetToken[:i],
+				Range:                sheetToken[i+1:],
+				ValueRenderOption:    "FormattedValue",
+				DateTimeRenderOption: "FormattedString",
+			},
+			NeedTenantAccessToken: true,
+		}, resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Data == nil || resp.Data.ValueRange == nil {
+		return nil, nil
+	}
+	rows := make([][]string, len(resp.Data.ValueRange.Values))
+	for i, values := range resp.Data.ValueRange.Values {
+		rows[i] = make([]string, len(values))
+		for j, value := range values {
+			rows[i][j] = sheetCellText(value)
+		}
+	}
+	return trimSheetRows(rows), nil
+}
+
+// FetchSheets fills the tables of the sheets embedded in the document. The
+// values of a sheet with more than maxCells cells are passed to save as CSV
+// instead, which returns the link to them. The sheets that cannot be
+// fetched are left out, the first error is returned after trying them all.
+func (c *Client) FetchSheets(
+	ctx context.Context, doc *ast.Document, maxCells int,
+	save func(sheet *ast.Sheet, data []byte) (string, error),
+) error {
+	var sheets []*ast.Sheet
+	ast.Walk(doc, func(n ast.Node) bool {
+		if sheet, ok := n.(*ast.Sheet); ok {
+			sheets = append(sheets, sheet)
+		}
+		return true
+	})
+
+	var firstErr error
+	for _, sheet := range sheets {
+		rows, err := c.GetSheetValues(ctx, sheet.Token)
+		if err == nil && len(rows) > 0 {
+			if SheetCells(rows) <= maxCells {
+				sheet.Table = SheetTable(rows)
+				continue
+			}
+			var data []byte
+			if data, err = SheetCSV(rows); err == nil {
+				sheet.Src, err = save(sheet, data)
+			}
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// sheetCellText flattens a cell to text, a cell is a plain value, a link or
+// mention object, a dropdown list or an array of rich text segments.
+func sheetCellText(raw json.RawMessage) string {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return ""
+	}
+	return sheetValueText(value)
+}
+
+func sheetValueText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	case []interface{}:
+		buf := new(strings.Builder)
+		for _, segment := range v {
+			buf.WriteString(sheetValueText(segment))
+		}
+		return buf.String()
+	case map[string]interface{}:
+		if values, ok := v["values"].([]interface{}); ok {
+			texts := make([]string, len(values))
+			for i, item := range values {
+				texts[i] = sheetValueText(item)
+			}
+			return strings.Join(texts, ", ")
+		}
+		if text, ok := v["text"].(string); ok && text != "" {
+			return text
+		}
+		if link, ok := v["link"].(string); ok {
+			return link
+		}
+	}
+	return ""
+}
+
+func trimSheetRows(rows [][]string) [][]string {
+	width := 0
+	height := 0
+	for i, row := range rows {
+		for j, cell := range row {
+			if cell != "" {
+				height = i + 1
+				if j+1 > width {
+					width = j + 1
+				}
+			}
+		}
+	}
+	rows = rows[:height]
+	for i, row := range rows {
+		trimmed := make([]string, width)
+		copy(trimmed, row)
+		rows[i] = trimmed
+	}
+	return rows
+}
+
+// SheetCells counts the cells of the values returned by GetSheetValues
+func SheetCells(rows [][]string) int {
+	if len(rows) == 0 {
+		return 0
+	}
+	return len(rows) * len(rows[0])
+}
+
+// SheetTable turns sheet values into a table, the first row being the
+// header as in the tables of a docx.
+func SheetTable(rows [][]string) *ast.Table {
+	table := &ast.Table{Rows: make([][]*ast.TableCell, len(rows))}
+	for i, row := range rows {
+		table.Rows[i] = make([]*ast.TableCell, len(row))
+		for j, value := range row {
+			cell := &ast.TableCell{RowSpan: 1, ColSpan: 1}
+			if value != "" {
+				cell.Children = []ast.Block{
+					&ast.Paragraph{Content: []ast.Inline{&ast.Text{Content: value}}},
+				}
+			}
+			table.Rows[i][j] = cell
+		}
+	}
+	return table
+}
+
+// SheetCSV encodes sheet values as CSV
+func SheetCSV(rows [][]string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	writer := csv.NewWriter(buf)
+	if err := writer.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
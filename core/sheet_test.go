@@ -0,0 +1,55 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Wsine/feishu2md/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSheetCellText(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{`"text"`, "text"},
+		{`1.50`, "1.50"},
+		{`-3`, "-3"},
+		{`true`, "true"},
+		{`null`, ""},
+		{`{"type":"url","text":"site","link":"https://example.com"}`, "site"},
+		{`{"type":"url","text":"","link":"https://example.com"}`, "https://example.com"},
+		{`{"type":"multipleValue","values":["a",2]}`, "a, 2"},
+		{`[{"type":"text","text":"see "},{"type":"url","text":"here","link":"https://example.com"}]`, "see here"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			assert.Equal(t, tt.want, sheetCellText(json.RawMessage(tt.raw)))
+		})
+	}
+}
+
+func TestTrimSheetRows(t *testing.T) {
+	rows := trimSheetRows([][]string{
+		{"a", "b", ""},
+		{"c", "", ""},
+		{"", "", ""},
+	})
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", ""}}, rows)
+	assert.Equal(t, 4, SheetCells(rows))
+	assert.Empty(t, trimSheetRows([][]string{{"", ""}}))
+}
+
+func TestSheetTable(t *testing.T) {
+	table := SheetTable([][]string{{"name", "value"}, {"x", ""}})
+	assert.Len(t, table.Rows, 2)
+	assert.Equal(t, []ast.Block{
+		&ast.Paragraph{Content: []ast.Inline{&ast.Text{Content: "x"}}},
+	}, table.Rows[1][0].Children)
+	assert.Empty(t, table.Rows[1][1].Children)
+
+	data, err := SheetCSV([][]string{{"name", "value"}, {"a,b", "1"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "name,value\n\"a,b\",1\n", string(data))
+}
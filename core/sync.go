@@ -0,0 +1,84 @@
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// SyncState records the exported documents of a sync target, keyed by the
+// document id, such that unchanged documents are skipped on the next run.
+type SyncState struct {
+	Documents map[string]*SyncRecord `json:"documents"`
+}
+
+// SyncRecord is an exported document, the assets are the images,
+// attachments and data files written along with it.
+type SyncRecord struct {
+	RevisionID int64    `json:"revision_id"`
+	Path       string   `json:"path"`
+	URL        string   `json:"url"`
+	Assets     []string `json:"assets,omitempty"`
+}
+
+func NewSyncState() *SyncState {
+	return &SyncState{Documents: make(map[string]*SyncRecord)}
+}
+
+// ReadSyncStateFromFile returns an empty state if the file does not exist,
+// which is the case of the first sync.
+func ReadSyncStateFromFile(statePath string) (*SyncState, error) {
+	file, err := ioutil.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return NewSyncState(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := NewSyncState()
+	if err = json.Unmarshal(file, state); err != nil {
+		return nil, err
+	}
+	if state.Documents == nil {
+		state.Documents = make(map[string]*SyncRecord)
+	}
+	return state, nil
+}
+
+func (state *SyncState) WriteSyncState2File(statePath string) error {
+	err := os.MkdirAll(filepath.Dir(statePath), 0o755)
+	if err != nil {
+		return err
+	}
+	file, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(statePath, file, 0o644)
+	return err
+}
+
+// IsUpToDate tells whether the record of the document matches the given
+// revision and path, and the file is still there.
+func (state *SyncState) IsUpToDate(documentID string, revisionID int64, path string) bool {
+	record, ok := state.Documents[documentID]
+	if !ok || record.RevisionID != revisionID || record.Path != path {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Paths returns the files of all the recorded documents including their
+// assets, which are still in use and must not be cleaned up.
+func (state *SyncState) Paths() map[string]bool {
+	paths := make(map[string]bool)
+	for _, record := range state.Documents {
+		paths[record.Path] = true
+		for _, asset := range record.Assets {
+			paths[asset] = true
+		}
+	}
+	return paths
+}
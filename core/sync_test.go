@@ -0,0 +1,55 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncState(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state", "sync.json")
+	docPath := filepath.Join(dir, "doc.md")
+
+	state, err := core.ReadSyncStateFromFile(statePath)
+	assert.Nil(t, err)
+	assert.Empty(t, state.Documents)
+
+	state.Documents["doxcn1"] = &core.SyncRecord{RevisionID: 3, Path: docPath}
+	assert.Nil(t, state.WriteSyncState2File(statePath))
+
+	state, err = core.ReadSyncStateFromFile(statePath)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), state.Documents["doxcn1"].RevisionID)
+
+	// the exported file is missing
+	assert.False(t, state.IsUpToDate("doxcn1", 3, docPath))
+
+	assert.Nil(t, os.WriteFile(docPath, []byte("# doc\n"), 0o644))
+	assert.True(t, state.IsUpToDate("doxcn1", 3, docPath))
+	assert.False(t, state.IsUpToDate("doxcn1", 4, docPath))
+	assert.False(t, state.IsUpToDate("doxcn1", 3, filepath.Join(dir, "moved.md")))
+	assert.False(t, state.IsUpToDate("doxcn2", 3, docPath))
+}
+
+func TestSyncStatePaths(t *testing.T) {
+	state := core.NewSyncState()
+	state.Documents["doxcn1"] = &core.SyncRecord{
+		Path:   "a.md",
+		Assets: []string{"static/shared.png", "attachments/boxcn1/spec.pdf"},
+	}
+	state.Documents["doxcn2"] = &core.SyncRecord{
+		Path:   "b.md",
+		Assets: []string{"static/shared.png"},
+	}
+
+	assert.Equal(t, map[string]bool{
+		"a.md":                        true,
+		"b.md":                        true,
+		"static/shared.png":           true,
+		"attachments/boxcn1/spec.pdf": true,
+	}, state.Paths())
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Wsine/feishu2md/ast"
+	"github.com/chyroc/lark"
+)
+
+type UserInfo struct {
+	Name  string
+	Email string
+}
+
+// userCache remembers the looked up users for the lifetime of a client,
+// including the failures such that a missing permission is only hit once
+// per user.
+type userCache struct {
+	mu    sync.Mutex
+	users map[string]*UserInfo
+	errs  map[string]error
+}
+
+// GetUserInfo looks up a user by the open id found in the mentions.
+func (c *Client) GetUserInfo(ctx context.Context, userID string) (*UserInfo, error) {
+	c.users.mu.Lock()
+	if user, ok := c.users.users[userID]; ok {
+		c.users.mu.Unlock()
+		return user, nil
+	}
+	if err, ok := c.users.errs[userID]; ok {
+		c.users.mu.Unlock()
+		return nil, err
+	}
+	c.users.mu.Unlock()
+
+	idType := lark.IDTypeOpenID
+	var resp *lark.GetUserResp
+	err := c.do(ctx, func() (r *lark.Response, err error) {
+		resp, r, err = c.larkClient.Contact.GetUser(ctx, &lark.GetUserReq{
+			UserID:     userID,
+			UserIDType: &idType,
+		})
+		return r, err
+	})
+
+	c.users.mu.Lock()
+	defer c.users.mu.Unlock()
+	if err != nil {
+		if c.users.errs == nil {
+			c.users.errs = make(map[string]error)
+		}
+		c.users.errs[userID] = err
+		return nil, err
+	}
+	user := &UserInfo{Name: resp.User.Name, Email: resp.User.Email}
+	if c.users.users == nil {
+		c.users.users = make(map[string]*UserInfo)
+	}
+	c.users.users[userID] = user
+	return user, nil
+}
+
+// ResolveUserMentions fills the names and emails of the mentioned users.
+// The users that cannot be looked up keep their ids, the first of these
+// errors is returned after trying all the mentions.
+func (c *Client) ResolveUserMentions(ctx context.Context, doc *ast.Document) error {
+	var firstErr error
+	ast.Walk(doc, func(n ast.Node) bool {
+		m, ok := n.(*ast.UserMention)
+		if !ok {
+			return true
+		}
+		user, err := c.GetUserInfo(ctx, m.UserID)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return true
+		}
+		m.Name = user.Name
+		m.Email = user.Email
+		return true
+	})
+	return firstErr
+}
@@ -11,13 +11,13 @@ require (
 )
 
 require (
+	github.com/alecthomas/chroma v0.9.2
 	github.com/gin-gonic/gin v1.9.0
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.8.1
 )
 
 require (
-	github.com/alecthomas/chroma v0.9.2 // indirect
 	github.com/bytedance/sonic v1.8.0 // indirect
 	github.com/chenzhuoyu/base64x v0.0.0-20221115062448-fe3a3abad311 // indirect
 	github.com/cpuguy83/go-md2man/v2 v2.0.1 // indirect
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var invalidFileNameChars = regexp.MustCompile(`[\\/:*?"<>|\x00-\x1f]`)
+
+// SanitizeFileName replaces the characters that are not allowed in a file
+// name on common platforms, an empty result falls back to the given name.
+func SanitizeFileName(name, fallback string) string {
+	name = invalidFileNameChars.ReplaceAllString(name, "_")
+	name = strings.Trim(strings.TrimSpace(name), ".")
+	if name == "" {
+		return fallback
+	}
+	return name
+}
+
+// FormatFileSize formats a size in bytes with a binary unit, such as
+// "1.5 MB", for display next to a link.
+func FormatFileSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit && exp < 4; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTP"[exp])
+}
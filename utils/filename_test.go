@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	type args struct {
+		name     string
+		fallback string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "valid name, keep it",
+			args: args{name: "飞书文档 v2", fallback: "token"},
+			want: "飞书文档 v2",
+		},
+		{
+			name: "path separators and reserved chars are replaced",
+			args: args{name: "a/b\\c: d?", fallback: "token"},
+			want: "a_b_c_ d_",
+		},
+		{
+			name: "empty name, use fallback",
+			args: args{name: " .. ", fallback: "token"},
+			want: "token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeFileName(tt.args.name, tt.args.fallback); got != tt.want {
+				t.Errorf("SanitizeFileName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatFileSize(t *testing.T) {
+	type args struct {
+		size int64
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "bytes",
+			args: args{size: 512},
+			want: "512 B",
+		},
+		{
+			name: "kilobytes",
+			args: args{size: 1536},
+			want: "1.5 KB",
+		},
+		{
+			name: "megabytes",
+			args: args{size: 3 << 20},
+			want: "3.0 MB",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatFileSize(tt.args.size); got != tt.want {
+				t.Errorf("FormatFileSize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
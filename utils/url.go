@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"fmt"
 	"net/url"
+	"strings"
 )
 
 func UnescapeURL(rawURL string) string {
@@ -10,3 +12,22 @@ func UnescapeURL(rawURL string) string {
 	}
 	return rawURL
 }
+
+// linkPathSpecials end a markdown link destination or change the meaning
+// of a relative URL when left in a file path.
+const linkPathSpecials = " \"#%()<>?[\\]^`{|}"
+
+// EscapeLinkPath percent-encodes the characters of a slash separated file
+// path that are not allowed in a link, such as spaces and parentheses from
+// document titles. Other characters, CJK included, are kept as is.
+func EscapeLinkPath(p string) string {
+	buf := new(strings.Builder)
+	for _, r := range p {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(linkPathSpecials, r) {
+			fmt.Fprintf(buf, "%%%02X", r)
+		} else {
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
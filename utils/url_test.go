@@ -43,3 +43,50 @@ func TestUnescapeURL(t *testing.T) {
 		})
 	}
 }
+
+func TestEscapeLinkPath(t *testing.T) {
+	type args struct {
+		p string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "plain path is kept",
+			args: args{
+				p: "static/doxcnABC123.png",
+			},
+			want: "static/doxcnABC123.png",
+		},
+		{
+			name: "spaces and parentheses are escaped",
+			args: args{
+				p: "../Team Space/My Doc (v2).md",
+			},
+			want: "../Team%20Space/My%20Doc%20%28v2%29.md",
+		},
+		{
+			name: "cjk is kept",
+			args: args{
+				p: "static/飞书文档 v2-1.png",
+			},
+			want: "static/飞书文档%20v2-1.png",
+		},
+		{
+			name: "percent and hash are escaped",
+			args: args{
+				p: "attachments/boxcn1/100% #1.pdf",
+			},
+			want: "attachments/boxcn1/100%25%20%231.pdf",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EscapeLinkPath(tt.args.p); got != tt.want {
+				t.Errorf("EscapeLinkPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
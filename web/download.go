@@ -10,10 +10,10 @@ import (
 	"net/url"
 	"os"
 	"regexp"
-	"strings"
 
-	"github.com/88250/lute"
+	"github.com/Wsine/feishu2md/ast"
 	"github.com/Wsine/feishu2md/core"
+	"github.com/Wsine/feishu2md/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -38,6 +38,14 @@ func downloadHandler(c *gin.Context) {
 		os.Getenv("FEISHU_APP_SECRET"),
 	)
 
+	format := c.DefaultQuery("format", core.FormatMarkdown)
+	renderer, err := core.NewRenderer(format, config.Output)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid output format")
+		return
+	}
+	ext := core.FormatExtensions[format]
+
 	domain := matchResult[1]
 	docType := matchResult[2]
 	docToken := matchResult[3]
@@ -46,10 +54,10 @@ func downloadHandler(c *gin.Context) {
 
 	client := core.NewClient(
 		config.Feishu.AppId, config.Feishu.AppSecret, domain,
+		core.WithRequestConfig(config.Request),
 	)
 
-	parser := core.NewParser(ctx)
-	markdown := ""
+	parser := core.NewParser()
 
 	// for a wiki page, we need to renew docType and docToken first
 	if docType == "wiki" {
@@ -63,47 +71,102 @@ func downloadHandler(c *gin.Context) {
 		docToken = node.ObjToken
 	}
 
-	docx, blocks, err := client.GetDocxContent(ctx, docToken)
+	docx, blocks, props, err := client.GetDocxContent(ctx, docToken)
 	if err != nil {
 		c.String(http.StatusInternalServerError, "Internal error: client.GetDocxContent")
 		log.Panicf("error: %s", err)
 		return
 	}
-	markdown = parser.ParseDocxContent(docx, blocks)
+	parser.BlockProps = props
+	document := parser.ParseDocxContent(docx, blocks)
 
 	zipBuffer := new(bytes.Buffer)
 	writer := zip.NewWriter(zipBuffer)
-	for _, imgToken := range parser.ImgTokens {
-		localLink, rawImage, err := client.DownloadImageRaw(ctx, imgToken, config.Output.ImageDir)
-		if err != nil {
-			c.String(http.StatusInternalServerError, "Internal error: client.DownloadImageRaw")
-			log.Panicf("error: %s", err)
-			return
+	localLinks := make(map[string]string)
+	imgDir := config.Output.ImageDir
+	if config.Output.ImagePerDocDir {
+		imgDir = imgDir + "/" + docx.DocumentID
+	}
+	naming := core.ImageNaming{Strategy: config.Output.ImageNaming, Title: docx.Title}
+	downloads, err := client.DownloadImagesRaw(
+		ctx, parser.ImgTokens, imgDir, naming,
+		config.Output.ImageWorkers, config.Output.SkipFailedImages,
+	)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Internal error: client.DownloadImagesRaw")
+		log.Panicf("error: %s", err)
+		return
+	}
+	written := make(map[string]bool)
+	// saveFile adds a file the document links to into the zip, identical
+	// images share a file with the sha256 naming
+	saveFile := func(path string, data []byte) (string, error) {
+		if !written[path] {
+			f, err := writer.Create(path)
+			if err != nil {
+				return "", err
+			}
+			if _, err = f.Write(data); err != nil {
+				return "", err
+			}
+			written[path] = true
 		}
-		markdown = strings.Replace(markdown, imgToken, localLink, 1)
-		f, err := writer.Create(localLink)
-		if err != nil {
-			c.String(http.StatusInternalServerError, "Internal error: zipWriter.Create")
-			log.Panicf("error: %s", err)
-			return
+		return utils.EscapeLinkPath(path), nil
+	}
+	for _, d := range downloads {
+		if d.Err != nil {
+			log.Printf("error: client.DownloadImagesRaw: %s", d.Err)
+			continue
 		}
-		_, err = f.Write(rawImage)
+		link, err := saveFile(d.Path, d.Raw)
 		if err != nil {
-			c.String(http.StatusInternalServerError, "Internal error: zipWriter.Create.Write")
+			c.String(http.StatusInternalServerError, "Internal error: zipWriter.Create")
 			log.Panicf("error: %s", err)
 			return
 		}
+		localLinks[d.Token] = link
 	}
 
-	engine := lute.New(func(l *lute.Lute) {
-		l.RenderOptions.AutoSpace = true
+	// whiteboards are exported as images, numbered after the others
+	err = client.ExportWhiteboards(
+		ctx, document, docx.RevisionID, len(parser.ImgTokens), imgDir, naming,
+		config.Output.ImageWorkers, saveFile,
+	)
+	if err != nil {
+		log.Printf("error: client.ExportWhiteboards: %s", err)
+	}
+	core.LinkDiagramSources(document, feishu_docx_url)
+
+	if err := client.DownloadFiles(ctx, document, config.Output.AttachmentDir, saveFile); err != nil {
+		log.Printf("error: client.DownloadFiles: %s", err)
+	}
+	err = client.FetchSheets(ctx, document, config.Output.SheetMaxCells, func(sheet *ast.Sheet, data []byte) (string, error) {
+		return saveFile(docToken+"-"+sheet.Token+".csv", data)
 	})
-	result := engine.FormatStr("md", markdown)
+	if err != nil {
+		log.Printf("error: client.FetchSheets: %s", err)
+	}
+	err = client.FetchBitables(ctx, document, config.Output.BitableStyle, config.Output.BitableTimeZone, config.Output.SheetMaxCells, func(bitable *ast.Bitable, data []byte, ext string) (string, error) {
+		return saveFile(docToken+"-"+bitable.Token+ext, data)
+	})
+	if err != nil {
+		log.Printf("error: client.FetchBitables: %s", err)
+	}
+
+	ast.Walk(document, func(n ast.Node) bool {
+		if img, ok := n.(*ast.Image); ok && localLinks[img.Token] != "" {
+			img.Src = localLinks[img.Token]
+		}
+		return true
+	})
+	result := renderer.Render(document)
+	if format == core.FormatMarkdown {
+		result = core.PrettifyMarkdown(result, config.Output)
+	}
 
 	// Set response
-	if len(parser.ImgTokens) > 0 {
-		mdName := fmt.Sprintf("%s.md", docToken)
-		f, err := writer.Create(mdName)
+	if len(written) > 0 {
+		f, err := writer.Create(docToken + ext)
 		if err != nil {
 			c.String(http.StatusInternalServerError, "Internal error: zipWriter.Create")
 			log.Panicf("error: %s", err)
@@ -125,7 +188,7 @@ func downloadHandler(c *gin.Context) {
 		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, docToken))
 		c.Data(http.StatusOK, "application/octet-stream", zipBuffer.Bytes())
 	} else {
-		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.md"`, docToken))
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s%s"`, docToken, ext))
 		c.Data(http.StatusOK, "application/octet-stream", []byte(result))
 	}
 }